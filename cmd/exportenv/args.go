@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// argCheck checks the arguments and returns an error describing invalid values or combinations.
+type argCheck func(args Args) error
+
+// reject returns an argCheck that fails with message if invalid reports true for the arguments.
+func reject(invalid func(args Args) bool, message string) argCheck {
+	return func(args Args) error {
+		if invalid(args) {
+			return errors.New(message)
+		}
+		return nil
+	}
+}
+
+// unixShells are the shells supported by --shell-escape and --no-export-keyword.
+var unixShells = []string{"bash", "sh", "zsh"}
+
+// argChecks are the checks of the arguments, in the order they are reported. They run on the arguments
+// as given, before prepareArgs derives other arguments from them.
+var argChecks = []argCheck{
+	func(args Args) error {
+		if _, ok := logLevels[args.LogLevel]; !ok {
+			return fmt.Errorf("unknown log level %q", args.LogLevel)
+		}
+		return nil
+	},
+	func(args Args) error {
+		if _, ok := formatters[args.Format]; !ok {
+			return fmt.Errorf("unknown output format %q", args.Format)
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return args.KeysOnly && (args.ValuesOnly || len(args.Keys) > 0)
+	}, "--keys-only cannot be combined with --values-only or --key"),
+	reject(func(args Args) bool {
+		return (args.KeysOnly || args.ValuesOnly || len(args.Keys) > 0 || args.NullTerminated) && args.Format != "export"
+	}, "--keys-only, --values-only, --key and --null-terminated cannot be combined with --format"),
+	func(args Args) error {
+		if _, ok := shellFormatters[args.Shell]; !ok {
+			return fmt.Errorf("unknown shell %q", args.Shell)
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return args.ShellEscape && !slices.Contains(unixShells, args.Shell)
+	}, "--shell-escape is only supported for bash, sh and zsh"),
+	reject(func(args Args) bool {
+		return args.NoExportKeyword && (args.Format != "export" || !slices.Contains(unixShells, args.Shell))
+	}, "--no-export-keyword is only supported for the export format with bash, sh and zsh"),
+	func(args Args) error {
+		if args.EnvFileFormat != "" && !slices.Contains(envparse.Formats, envparse.Format(args.EnvFileFormat)) {
+			return fmt.Errorf("unknown env file format %q", args.EnvFileFormat)
+		}
+		return nil
+	},
+	func(args Args) error {
+		return validPatterns(args.Exclude)
+	},
+	func(args Args) error {
+		_, err := parseRenames(args.Renames)
+		return err
+	},
+	reject(func(args Args) bool {
+		return args.Namespace != "" && args.AddPrefix != ""
+	}, "--namespace and --add-prefix cannot be used together"),
+	func(args Args) error {
+		if args.Namespace != "" && !variableName.MatchString(args.Namespace) {
+			return fmt.Errorf("invalid namespace %q", args.Namespace)
+		}
+		return nil
+	},
+	func(args Args) error {
+		if args.Namespace == "" {
+			return nil
+		}
+		// The command's other variables are left alone, so only namespaced keys may be unset
+		for _, key := range args.Unset {
+			if !strings.HasPrefix(key, namespacePrefix(args.Namespace)) {
+				return fmt.Errorf("--unset %s is outside of --namespace %s", key, args.Namespace)
+			}
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return args.StripNamespace != "" && (args.Prefix != "" || args.StripPrefix != "")
+	}, "--strip-namespace cannot be combined with --prefix or --strip-prefix"),
+	func(args Args) error {
+		if args.StripNamespace != "" && !variableName.MatchString(args.StripNamespace) {
+			return fmt.Errorf("invalid namespace %q", args.StripNamespace)
+		}
+		return nil
+	},
+	func(args Args) error {
+		_, err := parseBoolFormat(args.BoolFormat)
+		return err
+	},
+	reject(func(args Args) bool {
+		return args.UppercaseKeys && args.LowercaseKeys
+	}, "--uppercase-keys and --lowercase-keys cannot be used together"),
+	func(args Args) error {
+		if !slices.Contains(envparse.Interpolations, envparse.Interpolation(args.InterpolationMode)) {
+			return fmt.Errorf("unknown interpolation mode %q", args.InterpolationMode)
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return (args.Template != "" || args.GenerateSchema) && len(args.Cmd) > 0
+	}, "--template and --generate-schema cannot be combined with a command"),
+	func(args Args) error {
+		if !slices.Contains(templateMissingModes, args.TemplateMissing) {
+			return fmt.Errorf("unknown --template-missing mode %q", args.TemplateMissing)
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return args.Interactive && args.OutputFile == ""
+	}, "--interactive requires --output-file"),
+	reject(func(args Args) bool {
+		return args.SkipInvalid && args.Strict
+	}, "--skip-invalid and --strict cannot be used together"),
+	reject(func(args Args) bool {
+		return args.FromPID < 0
+	}, "--from-pid must be a process ID"),
+	reject(func(args Args) bool {
+		return args.MaxValueLength < 0
+	}, "--max-value-length must not be negative"),
+	reject(func(args Args) bool {
+		return args.WarnMaxLength && args.MaxValueLength == 0
+	}, "--warn-max-length requires --max-value-length"),
+	reject(func(args Args) bool {
+		return args.Limit < 0
+	}, "--limit must not be negative"),
+	func(args Args) error {
+		// --no-sort selects the order "none" in prepareArgs
+		if _, ok := entrySorters[args.SortBy]; !ok && !args.NoSort {
+			return fmt.Errorf("unknown sort order %q", args.SortBy)
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return args.Dir != "" && len(args.Cmd) == 0
+	}, "--dir requires a command"),
+	reject(func(args Args) bool {
+		return args.Watch && len(args.Cmd) == 0
+	}, "--watch requires a command"),
+	reject(func(args Args) bool {
+		return args.Watch && slices.Contains(args.EnvFiles, envparse.Stdin)
+	}, "--watch cannot read env files from stdin"),
+	reject(func(args Args) bool {
+		return (args.SetIfEmpty || args.OnlyChanged) && args.CleanEnv
+	}, "--set-if-empty and --only-changed cannot be combined with --clean-env"),
+	reject(func(args Args) bool {
+		return args.Profile != "" && len(args.EnvFiles) > 0
+	}, "--profile and --env-file cannot be used together"),
+	func(args Args) error {
+		if args.Profile != "" && !profileName.MatchString(args.Profile) {
+			return fmt.Errorf("invalid profile %q, only letters and digits are allowed", args.Profile)
+		}
+		return nil
+	},
+	reject(func(args Args) bool {
+		return args.Cascade && (len(args.EnvFiles) > 0 || args.Profile != "")
+	}, "--cascade cannot be combined with --env-file or --profile"),
+	reject(func(args Args) bool {
+		return args.VaultPasswordFile != "" && args.VaultPasswordEnv != ""
+	}, "--vault-password-file and --vault-password-env cannot be used together"),
+}
+
+// checkArgs runs the argChecks and returns the error of the first failing one.
+func checkArgs(args Args) error {
+	for _, check := range argChecks {
+		if err := check(args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareArgs derives the arguments that other arguments stand for, such as the env files of --profile,
+// and loads the files the arguments refer to. The arguments must have passed checkArgs.
+func prepareArgs(args *Args) error {
+	if args.Namespace != "" {
+		args.AddPrefix = namespacePrefix(args.Namespace)
+	}
+	if args.StripNamespace != "" {
+		args.Prefix = namespacePrefix(args.StripNamespace)
+		args.StripPrefix = args.Prefix
+	}
+	if args.NoSort {
+		args.SortBy = "none"
+	}
+	if args.Dir != "" {
+		dir, err := commandDir(args.Dir)
+		if err != nil {
+			return fmt.Errorf("invalid working directory: %w", err)
+		}
+		args.Dir = dir
+	}
+	if args.Profile != "" {
+		// Later profile files always take precedence, .env.local overrides everything
+		args.EnvFiles = profileFiles(args.Profile)
+		args.Override = true
+	}
+	if args.Cascade {
+		files, err := cascadeFiles()
+		if err != nil {
+			return fmt.Errorf("determining the hostname: %w", err)
+		}
+		args.EnvFiles = files
+		args.Override = true
+	}
+	if args.Schema != "" {
+		schema, err := loadSchema(args.Schema)
+		if err != nil {
+			return fmt.Errorf("loading schema: %w", err)
+		}
+		args.schema = schema
+	}
+	return nil
+}
+
+// outputFormatter returns the formatter selected by --format, --keys-only, --values-only, --key and
+// --null-terminated.
+func outputFormatter(args Args) formatter {
+	switch {
+	case args.KeysOnly:
+		return printKeys
+	case args.ValuesOnly || len(args.Keys) > 0:
+		return printValues
+	case args.NullTerminated:
+		return printNullTerminated
+	default:
+		return formatters[args.Format]
+	}
+}
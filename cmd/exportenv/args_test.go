@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alexflint/go-arg"
+)
+
+func TestCheckArgs(t *testing.T) {
+	tests := []struct {
+		argv    []string
+		wantErr string
+	}{
+		{argv: nil},
+		{argv: []string{"--format", "json", "--no-sort"}},
+		{argv: []string{"--log-level", "trace"}, wantErr: `unknown log level "trace"`},
+		{argv: []string{"--keys-only", "--values-only"}, wantErr: "--keys-only cannot be combined with --values-only or --key"},
+		{argv: []string{"--null-terminated", "--format", "json"}, wantErr: "--keys-only, --values-only, --key and --null-terminated cannot be combined with --format"},
+		{argv: []string{"--shell-escape", "--shell", "fish"}, wantErr: "--shell-escape is only supported for bash, sh and zsh"},
+		{argv: []string{"--namespace", "app", "--unset", "OTHER"}, wantErr: "--unset OTHER is outside of --namespace app"},
+		{argv: []string{"--sort-by", "size"}, wantErr: `unknown sort order "size"`},
+		{argv: []string{"--sort-by", "size", "--no-sort"}},
+		{argv: []string{"--watch", "--env-file", "-", "--", "true"}, wantErr: "--watch cannot read env files from stdin"},
+		{argv: []string{"--profile", "prod", "--env-file", ".env"}, wantErr: "--profile and --env-file cannot be used together"},
+		{argv: []string{"--cascade", "--profile", "prod"}, wantErr: "--cascade cannot be combined with --env-file or --profile"},
+	}
+	for _, tt := range tests {
+		var args Args
+		parser, err := arg.NewParser(arg.Config{}, &args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := parser.Parse(tt.argv); err != nil {
+			t.Fatalf("parsing %q: %v", tt.argv, err)
+		}
+
+		err = checkArgs(args)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("checkArgs(%q) = %v", tt.argv, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("checkArgs(%q) = %v, want %q", tt.argv, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPrepareArgs(t *testing.T) {
+	args := Args{StripNamespace: "app", NoSort: true, Profile: "test"}
+	if err := prepareArgs(&args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Prefix != "APP_" || args.StripPrefix != "APP_" {
+		t.Errorf("prefix = %q, strip prefix = %q, want APP_", args.Prefix, args.StripPrefix)
+	}
+	if args.SortBy != "none" {
+		t.Errorf("sort order = %q, want none", args.SortBy)
+	}
+	// Later profile files override earlier ones
+	if !args.Override {
+		t.Error("--profile doesn't enable override")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// auditRecord is a line of the --audit-log file.
+type auditRecord struct {
+	Time time.Time `json:"time"`
+	// Event is "load" for a variable loaded from the env files and "exec" for a variable of the
+	// environment a command is executed with.
+	Event string `json:"event"`
+	Key   string `json:"key"`
+	File  string `json:"file,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	// Overridden reports whether a loaded variable replaces a variable of the current environment.
+	Overridden bool   `json:"overridden,omitempty"`
+	Value      string `json:"value"`
+}
+
+// redactedPrefixLength is the number of characters of a value that are kept in the audit log.
+const redactedPrefixLength = 3
+
+// redact returns the first characters of value followed by ***. Values that are not longer than the
+// kept prefix are replaced completely.
+func redact(value string) string {
+	runes := []rune(value)
+	if len(runes) <= redactedPrefixLength {
+		return maskedValue
+	}
+	return string(runes[:redactedPrefixLength]) + maskedValue
+}
+
+// auditLoaded appends a record for each loaded variable to the audit log at path.
+func auditLoaded(path string, entries []envparse.Entry, cleanEnv bool) error {
+	now := time.Now()
+	records := make([]auditRecord, 0, len(entries))
+	for _, e := range entries {
+		_, defined := os.LookupEnv(e.Key)
+		records = append(records, auditRecord{
+			Time:       now,
+			Event:      "load",
+			Key:        e.Key,
+			File:       e.File,
+			Line:       e.Line,
+			Overridden: defined && !cleanEnv,
+			Value:      redact(e.Value),
+		})
+	}
+	return appendAudit(path, records)
+}
+
+// auditExec appends a record for each variable of the environment a command is executed with to the
+// audit log at path.
+func auditExec(path string, env []string) error {
+	now := time.Now()
+	records := make([]auditRecord, 0, len(env))
+	for _, v := range env {
+		key, value, _ := strings.Cut(v, "=")
+		records = append(records, auditRecord{Time: now, Event: "exec", Key: key, Value: redact(value)})
+	}
+	return appendAudit(path, records)
+}
+
+// appendAudit appends the records to the audit log at path as JSON Lines.
+func appendAudit(path string, records []auditRecord) error {
+	// Even redacted, the log hints at secrets, so it is only readable by the current user
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return errors.Join(err, f.Close())
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := map[string]string{
+		"":             "***",
+		"abc":          "***",
+		"abcd":         "abc***",
+		"s3cr3t-value": "s3c***",
+		"äöüß":         "äöü***",
+	}
+	for value, want := range tests {
+		if got := redact(value); got != want {
+			t.Errorf("redact(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestAppendAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := auditExec(path, []string{"A=secret", "B="}); err != nil {
+		t.Fatal(err)
+	}
+	if err := auditExec(path, []string{"C=value"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d records, want 3:\n%s", len(lines), content)
+	}
+	var record auditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Event != "exec" || record.Key != "A" || record.Value != "sec***" {
+		t.Errorf("first record = %+v", record)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("audit log mode = %04o, want 0600", perm)
+	}
+}
+
+func TestAppendAuditError(t *testing.T) {
+	if err := appendAudit(t.TempDir(), []auditRecord{{Key: "A"}}); err == nil {
+		t.Error("appendAudit() to a directory succeeded, want an error")
+	}
+}
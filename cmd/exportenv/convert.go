@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// ConvertArgs are the arguments of the convert subcommand.
+type ConvertArgs struct {
+	From string `arg:"--from" help:"Input format: dotenv, json, yaml, toml. Detected by the file extension by default"`
+	To   string `arg:"--to,required" help:"Output format: dotenv, json, yaml, toml"`
+	File string `arg:"positional,required" help:"File to convert, - for stdin"`
+}
+
+// runConvert runs the convert subcommand, which reads variables in one format and prints them in another.
+// Values are converted as written, without expanding references.
+func runConvert(argv []string) int {
+	var args ConvertArgs
+	parser, code, ok := parseSubcommand("exportenv convert", &args, argv)
+	if !ok {
+		return code
+	}
+
+	from := envparse.DetectFormat(args.File)
+	if args.From != "" {
+		from = envparse.Format(args.From)
+	}
+	for _, format := range []envparse.Format{from, envparse.Format(args.To)} {
+		if !slices.Contains(envparse.Formats, format) {
+			return usageError(parser, fmt.Sprintf("unknown format %q", format))
+		}
+	}
+
+	envVars, err := decodeFile(args.File, from)
+	if err != nil {
+		slog.Error("Error reading input", slog.Any("error", err))
+		return 1
+	}
+	if err := envparse.Encode(os.Stdout, envVars, envparse.Format(args.To)); err != nil {
+		slog.Error("Error writing output", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+// decodeFile reads the variables of a file in the given format. The file name "-" reads from stdin.
+func decodeFile(path string, format envparse.Format) (map[string]string, error) {
+	var r io.Reader = os.Stdin
+	if path != envparse.Stdin {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		// nolint: errcheck
+		defer f.Close()
+		r = f
+	}
+
+	envVars, err := envparse.Decode(r, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return envVars, nil
+}
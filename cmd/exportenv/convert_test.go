@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+func TestConvertKeepsReferences(t *testing.T) {
+	path := writeEnvFile(t, "b.env", "B=\"${A} world\"\nC='${A}'\nD=${A:-x}\nE=\"C:\\path\\n\"\n")
+	envVars, err := decodeFile(path, envparse.FormatDotenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := envparse.Encode(&buf, envVars, envparse.FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "B": "${A} world",
+  "C": "${A}",
+  "D": "${A:-x}",
+  "E": "C:\\path\\n"
+}
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
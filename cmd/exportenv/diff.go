@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// changeKind describes how a variable differs between two environments.
+type changeKind string
+
+const (
+	changeAdded   changeKind = "+"
+	changeRemoved changeKind = "-"
+	changeChanged changeKind = "~"
+)
+
+// ANSI colors used for each kind of change.
+var changeColors = map[changeKind]string{
+	changeAdded:   "\033[32m",
+	changeRemoved: "\033[31m",
+	changeChanged: "\033[33m",
+}
+
+const colorReset = "\033[0m"
+
+// change is a single difference between two environments.
+type change struct {
+	Kind changeKind
+	Key  string
+	Old  string
+	New  string
+}
+
+// diffEnvVars compares two environments and returns the differences sorted by key.
+// Variables missing from after are only reported if includeRemoved is true.
+func diffEnvVars(before, after map[string]string, includeRemoved bool) []change {
+	var changes []change
+	for key, value := range after {
+		old, exists := before[key]
+		switch {
+		case !exists:
+			changes = append(changes, change{Kind: changeAdded, Key: key, New: value})
+		case old != value:
+			changes = append(changes, change{Kind: changeChanged, Key: key, Old: old, New: value})
+		}
+	}
+	if includeRemoved {
+		for key, value := range before {
+			if _, exists := after[key]; !exists {
+				changes = append(changes, change{Kind: changeRemoved, Key: key, Old: value})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// printDiff prints the changes as +, - and ~ lines, optionally colored.
+func printDiff(w io.Writer, changes []change, color bool) error {
+	for _, c := range changes {
+		var line string
+		switch c.Kind {
+		case changeAdded:
+			line = fmt.Sprintf("+ %s=%s", c.Key, c.New)
+		case changeRemoved:
+			line = fmt.Sprintf("- %s=%s", c.Key, c.Old)
+		case changeChanged:
+			line = fmt.Sprintf("~ %s: %q -> %q", c.Key, c.Old, c.New)
+		}
+		if color {
+			line = changeColors[c.Kind] + line + colorReset
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// currentEnvVars returns the environment of the current process as a map.
+func currentEnvVars() map[string]string {
+	envVars := make(map[string]string)
+	for _, v := range os.Environ() {
+		key, value, _ := strings.Cut(v, "=")
+		envVars[key] = value
+	}
+	return envVars
+}
+
+// useColor reports whether f is a terminal and colors are not disabled with NO_COLOR.
+func useColor(f *os.File) bool {
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// showDiff prints how the environment resulting from the loaded variables differs from the current
+// environment and returns the exit code exportenv should exit with: 0 if nothing changes, 1 otherwise.
+func showDiff(envVars map[string]string, args Args) int {
+	current := currentEnvVars()
+
+	result := make(map[string]string)
+	if !args.CleanEnv {
+		for key, value := range current {
+			if !slices.Contains(args.Unset, key) {
+				result[key] = value
+			}
+		}
+	}
+	envparse.Merge(result, envVars)
+
+	changes := diffEnvVars(current, result, true)
+	for i, c := range changes {
+		if slices.Contains(args.MaskValues, c.Key) {
+			changes[i].Old, changes[i].New = maskedValue, maskedValue
+		}
+	}
+	if err := printDiff(os.Stdout, changes, useColor(os.Stdout)); err != nil {
+		slog.Error("Error writing output", slog.Any("error", err))
+		return 1
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// DiffArgs are the arguments of the diff subcommand.
+type DiffArgs struct {
+	Format   string   `arg:"--format" default:"text" help:"Output format: text, json"`
+	NoExpand bool     `arg:"--no-expand" help:"Disable variable expansion"`
+	Strict   bool     `arg:"--strict" help:"Reject shell-style export KEY=VALUE lines and abort on malformed lines in env files"`
+	Files    []string `arg:"positional,required" placeholder:"FILE" help:"The two env files to compare"`
+}
+
+// jsonChange is a change as printed by diff --format json.
+type jsonChange struct {
+	Kind string  `json:"kind"`
+	Key  string  `json:"key"`
+	Old  *string `json:"old,omitempty"`
+	New  *string `json:"new,omitempty"`
+}
+
+// changeKindNames are the names of the kinds of changes in the JSON output.
+var changeKindNames = map[changeKind]string{
+	changeAdded:   "added",
+	changeRemoved: "removed",
+	changeChanged: "changed",
+}
+
+// printJSONDiff prints the changes as a JSON array.
+func printJSONDiff(w io.Writer, changes []change) error {
+	jsonChanges := make([]jsonChange, len(changes))
+	for i, c := range changes {
+		jsonChanges[i] = jsonChange{Kind: changeKindNames[c.Kind], Key: c.Key}
+		if c.Kind != changeAdded {
+			jsonChanges[i].Old = &c.Old
+		}
+		if c.Kind != changeRemoved {
+			jsonChanges[i].New = &c.New
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonChanges)
+}
+
+// runDiff runs the diff subcommand, which compares two env files, and returns the exit code
+// exportenv should exit with: 0 if the files are identical, 1 if they differ and 2 on errors.
+func runDiff(argv []string) int {
+	var args DiffArgs
+	parser, code, ok := parseSubcommand("exportenv diff", &args, argv)
+	if !ok {
+		return code
+	}
+	if len(args.Files) != 2 {
+		return usageError(parser, "expected exactly two env files")
+	}
+	if args.Format != "text" && args.Format != "json" {
+		return usageError(parser, fmt.Sprintf("unknown output format %q", args.Format))
+	}
+
+	opts := []envparse.Option{
+		envparse.WithNoExpand(args.NoExpand),
+		envparse.WithStrict(args.Strict),
+		envparse.WithWarningHandler(func(issue envparse.Issue) {
+			fmt.Fprintln(os.Stderr, issue)
+		}),
+	}
+	var err error
+	envVars := make([]map[string]string, len(args.Files))
+	for i, file := range args.Files {
+		if envVars[i], err = envparse.Load([]string{file}, opts...); err != nil {
+			slog.Error("Error loading env file", slog.Any("error", err))
+			return 2
+		}
+	}
+
+	changes := diffEnvVars(envVars[0], envVars[1], true)
+	if args.Format == "json" {
+		err = printJSONDiff(os.Stdout, changes)
+	} else {
+		err = printDiff(os.Stdout, changes, useColor(os.Stdout))
+	}
+	if err != nil {
+		slog.Error("Error writing output", slog.Any("error", err))
+		return 2
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestRunDiff(t *testing.T) {
+	a := writeEnvFile(t, "a.env", "A=1\nB=2\nC=3\n")
+	b := writeEnvFile(t, "b.env", "A=1\nB=two\nD=4\n")
+
+	tests := []struct {
+		name     string
+		argv     []string
+		wantCode int
+		want     string
+	}{
+		{name: "identical", argv: []string{a, a}, wantCode: 0, want: ""},
+		{name: "different", argv: []string{a, b}, wantCode: 1, want: "~ B: \"2\" -> \"two\"\n- C=3\n+ D=4\n"},
+		{
+			name:     "json",
+			argv:     []string{"--format", "json", a, b},
+			wantCode: 1,
+			want: `[
+  {
+    "kind": "changed",
+    "key": "B",
+    "old": "2",
+    "new": "two"
+  },
+  {
+    "kind": "removed",
+    "key": "C",
+    "old": "3"
+  },
+  {
+    "kind": "added",
+    "key": "D",
+    "new": "4"
+  }
+]
+`,
+		},
+		{name: "missing file", argv: []string{a, a + ".missing"}, wantCode: 2},
+		{name: "one file", argv: []string{a}, wantCode: 2},
+		{name: "unknown format", argv: []string{"--format", "yaml", a, b}, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := runSubcommand(t, runDiff, tt.argv...)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
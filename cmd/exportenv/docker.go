@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// containerManagedVars are set by the image or Docker for the container's own file system and host,
+// and never imported from a container.
+var containerManagedVars = map[string]bool{"HOSTNAME": true, "PATH": true}
+
+// containerEnv returns the environment of a Docker container, as configured when it was created.
+// It runs the docker CLI, which connects to the daemon selected by DOCKER_HOST, DOCKER_CONTEXT,
+// DOCKER_TLS_VERIFY and DOCKER_CERT_PATH like any docker command.
+func containerEnv(container string) (map[string]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", "inspect", "--type", "container", "--format", "{{json .Config.Env}}", container)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	var env []string
+	if err := json.Unmarshal(stdout.Bytes(), &env); err != nil {
+		return nil, fmt.Errorf("parsing docker inspect output: %w", err)
+	}
+	envVars := make(map[string]string, len(env))
+	for _, v := range env {
+		if key, value, ok := strings.Cut(v, "="); ok && key != "" && !containerManagedVars[key] {
+			envVars[key] = value
+		}
+	}
+	return envVars, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// secretPlaceholder is the example value of variables that look like secrets.
+const secretPlaceholder = "YOUR_SECRET_HERE"
+
+// generateExample writes path.example with the keys of the env file at path and returns the exit code
+// exportenv should exit with. Values are left empty, or set to a placeholder if they look like secrets.
+// Values of an existing example file are kept, its keys missing from path are marked as deprecated.
+func generateExample(path string) int {
+	warn := envparse.WithWarningHandler(func(issue envparse.Issue) {
+		fmt.Fprintln(os.Stderr, issue)
+	})
+	entries, err := envparse.LoadEntries([]string{path}, envparse.WithNoExpand(true), warn)
+	if err != nil {
+		slog.Error("Error loading env file", slog.Any("error", err))
+		return 1
+	}
+	// Example files are meant to be readable by everyone, so their warnings are not reported
+	examplePath := path + ".example"
+	existing, err := envparse.LoadEntries([]string{examplePath}, envparse.WithNoExpand(true), envparse.WithIgnoreMissing(true))
+	if err != nil {
+		slog.Error("Error loading example file", slog.Any("error", err))
+		return 1
+	}
+
+	content, err := exampleContent(path, entries, existing)
+	if err != nil {
+		slog.Error("Error generating example file", slog.Any("error", err))
+		return 1
+	}
+	err = writeFileAtomic(examplePath, 0o644, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+	if err != nil {
+		slog.Error("Error writing example file", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+// exampleContent returns the content of the example file for the entries of the env file path,
+// merged with the entries of the existing example file.
+func exampleContent(path string, entries, existing []envparse.Entry) ([]byte, error) {
+	previous := envparse.ToMap(existing)
+	content := []byte(fmt.Sprintf("# Generated by exportenv from %s\n", path))
+
+	var err error
+	defined := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		defined[e.Key] = true
+		value, ok := previous[e.Key]
+		switch {
+		case ok:
+		case looksSecret(e.Key, e.Value):
+			value = secretPlaceholder
+		default:
+			value = ""
+		}
+		if content, err = envparse.SetValue(content, e.Key, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range existing {
+		if defined[e.Key] {
+			continue
+		}
+		content = append(content, "# deprecated\n"...)
+		if content, err = envparse.SetValue(content, e.Key, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
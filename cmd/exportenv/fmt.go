@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// FmtArgs are the arguments of the fmt subcommand.
+type FmtArgs struct {
+	DryRun        bool     `arg:"--dry-run" help:"Print the changes instead of writing the files, exit with 1 if a file would change"`
+	GroupByPrefix bool     `arg:"--group-by-prefix" help:"Separate variables whose keys differ before the first underscore by a blank line"`
+	Files         []string `arg:"positional" help:"Env files to format [default: .env]"`
+}
+
+// runFmt runs the fmt subcommand, which rewrites env files in place in the canonical form of
+// envparse.Reformat. Files that are already formatted are left untouched.
+func runFmt(argv []string) int {
+	var args FmtArgs
+	if _, code, ok := parseSubcommand("exportenv fmt", &args, argv); !ok {
+		return code
+	}
+	if len(args.Files) == 0 {
+		args.Files = []string{envparse.DefaultFile}
+	}
+
+	exitCode := 0
+	for _, file := range args.Files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			slog.Error("Error reading env file", slog.Any("error", err))
+			return 1
+		}
+		formatted, err := envparse.Reformat(content, args.GroupByPrefix)
+		if err != nil {
+			slog.Error("Error formatting env file", slog.String("file", file), slog.Any("error", err))
+			return 1
+		}
+		if bytes.Equal(content, formatted) {
+			continue
+		}
+
+		if args.DryRun {
+			if err := printLineDiff(os.Stdout, file, string(content), string(formatted), useColor(os.Stdout)); err != nil {
+				slog.Error("Error writing output", slog.Any("error", err))
+				return 1
+			}
+			exitCode = 1
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			slog.Error("Error reading env file", slog.Any("error", err))
+			return 1
+		}
+		err = writeFileAtomic(file, info.Mode().Perm(), func(w io.Writer) error {
+			_, err := w.Write(formatted)
+			return err
+		})
+		if err != nil {
+			slog.Error("Error writing env file", slog.Any("error", err))
+			return 1
+		}
+	}
+	return exitCode
+}
+
+// printLineDiff prints the lines removed from old with "-" and the lines added in new with "+",
+// unchanged lines with two spaces, below a header naming the file.
+func printLineDiff(w io.Writer, file, old, new string, color bool) error {
+	oldLines := strings.Split(strings.TrimSuffix(old, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(new, "\n"), "\n")
+
+	// common[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	common := make([][]int, len(oldLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s (formatted)\n", file, file); err != nil {
+		return err
+	}
+	// Unchanged lines have no kind
+	printLine := func(kind changeKind, line string) error {
+		if kind == "" {
+			line = "  " + line
+		} else {
+			line = string(kind) + " " + line
+		}
+		if color && kind != "" {
+			line = changeColors[kind] + line + colorReset
+		}
+		_, err := fmt.Fprintln(w, line)
+		return err
+	}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		var err error
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			err = printLine("", oldLines[i])
+			i, j = i+1, j+1
+		case j < len(newLines) && (i == len(oldLines) || common[i][j+1] >= common[i+1][j]):
+			err = printLine(changeAdded, newLines[j])
+			j++
+		default:
+			err = printLine(changeRemoved, oldLines[i])
+			i++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
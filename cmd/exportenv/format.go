@@ -0,0 +1,485 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+	"gopkg.in/yaml.v3"
+)
+
+// formatter writes sorted environment variables to w in a specific output format.
+type formatter func(w io.Writer, sortedEnvVars []string, args Args) error
+
+// formatters maps the values accepted by --format to their formatter.
+var formatters = map[string]formatter{
+	"export":         printShellEnvVars,
+	"github-matrix":  printGitHubMatrix,
+	"consul-kv":      printConsulKV,
+	"xcconfig":       printXcconfig,
+	"json":           printJSON,
+	"k8s-configmap":  printConfigMap,
+	"k8s-secret":     printSecret,
+	"tfvars":         printTfvars,
+	"github-actions": printGitHubActions,
+	"dotenv":         printDotenv,
+	"ansible-vars":   printAnsibleVars,
+	"shell-script":   printShellScript,
+}
+
+// shellFormatters maps the values accepted by --shell to the formatter used by the export format.
+var shellFormatters = map[string]formatter{
+	"bash": printExportableEnvVars,
+	"sh":   printExportableEnvVars,
+	"zsh":  printExportableEnvVars,
+	"fish": printFishEnvVars,
+	"pwsh": printPowerShellEnvVars,
+	"cmd":  printCmdEnvVars,
+}
+
+// printShellEnvVars prints environment variables in the syntax of the shell selected with --shell.
+func printShellEnvVars(w io.Writer, sortedEnvVars []string, args Args) error {
+	return shellFormatters[args.Shell](w, sortedEnvVars, args)
+}
+
+// printUnsetStatements prints a statement removing each of the keys, using the given format.
+func printUnsetStatements(w io.Writer, format string, keys []string) error {
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, format, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// needsShellEscape reports whether value contains characters that are special in double-quoted
+// strings of POSIX shells, including ! for the history expansion of interactive bash, or control
+// characters.
+func needsShellEscape(value string) bool {
+	for _, r := range value {
+		if strings.ContainsRune("\"\\$`!", r) || unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ansiCQuote quotes value as an ANSI-C string, $'...', in which no character is special except the
+// escape sequences. Control characters are written as escape sequences.
+func ansiCQuote(value string) string {
+	var b strings.Builder
+	b.WriteString("$'")
+	for _, c := range []byte(value) {
+		switch c {
+		case '\\', '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// fishEscaper escapes the characters that are special in double-quoted fish strings. Parentheses
+// need no escaping, command substitution inside double quotes always starts with $.
+var fishEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`)
+
+// printShellScript prints environment variables as a POSIX shell script that can be sourced or executed,
+// with a header naming the time it was generated and the env files it was generated from.
+func printShellScript(w io.Writer, sortedEnvVars []string, args Args) error {
+	sources := slices.Concat(args.EnvDirs, args.EnvFiles)
+	if len(sources) == 0 {
+		sources = []string{envparse.DefaultFile}
+	}
+	header := fmt.Sprintf("#!/usr/bin/env sh\n# Generated by exportenv at %s\n# Sources: %s\n\n",
+		time.Now().Format(time.RFC3339), strings.Join(sources, ", "))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	return printExportableEnvVars(w, sortedEnvVars, args)
+}
+
+// printFishEnvVars prints environment variables as fish set -x commands.
+func printFishEnvVars(w io.Writer, sortedEnvVars []string, args Args) error {
+	if err := printUnsetStatements(w, "set -e %s\n", args.Unset); err != nil {
+		return err
+	}
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		if _, err := fmt.Fprintf(w, "set -x %s \"%s\"\n", key, fishEscaper.Replace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// powerShellEscaper escapes the characters that are special in double-quoted PowerShell strings.
+var powerShellEscaper = strings.NewReplacer("`", "``", `"`, "`\"", "$", "`$", "\n", "`n", "\r", "`r", "\t", "`t")
+
+// printPowerShellEnvVars prints environment variables as PowerShell $env: assignments.
+func printPowerShellEnvVars(w io.Writer, sortedEnvVars []string, args Args) error {
+	if err := printUnsetStatements(w, "Remove-Item Env:%s -ErrorAction SilentlyContinue\n", args.Unset); err != nil {
+		return err
+	}
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		if _, err := fmt.Fprintf(w, "$env:%s = \"%s\"\n", key, powerShellEscaper.Replace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printCmdEnvVars prints environment variables as Windows cmd SET commands, suitable for batch files.
+func printCmdEnvVars(w io.Writer, sortedEnvVars []string, args Args) error {
+	if err := printUnsetStatements(w, "SET %s=\n", args.Unset); err != nil {
+		return err
+	}
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		// cmd has no way to represent line breaks in a variable
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s: multiline values are not supported by cmd", key)
+		}
+
+		// The quoted SET "KEY=VALUE" form keeps &, |, < and > literal, only % needs escaping.
+		if _, err := fmt.Fprintf(w, "SET \"%s=%s\"\n", key, strings.ReplaceAll(value, "%", "%%")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordTerminator returns the string ending each record of the plain output formats,
+// a null byte if --null-terminated is set and a newline otherwise.
+func recordTerminator(args Args) string {
+	if args.NullTerminated {
+		return "\x00"
+	}
+	return "\n"
+}
+
+// printNullTerminated prints environment variables as raw KEY=VALUE records without quoting,
+// each terminated by a null byte like the output of env -0.
+func printNullTerminated(w io.Writer, sortedEnvVars []string, args Args) error {
+	return printRecords(w, sortedEnvVars, args)
+}
+
+// printKeys prints the key of each environment variable as a record, without values.
+func printKeys(w io.Writer, sortedEnvVars []string, args Args) error {
+	keys := make([]string, len(sortedEnvVars))
+	for i, v := range sortedEnvVars {
+		keys[i], _, _ = strings.Cut(v, "=")
+	}
+	return printRecords(w, keys, args)
+}
+
+// printRecords prints each record followed by the terminator selected by --null-terminated.
+func printRecords(w io.Writer, records []string, args Args) error {
+	terminator := recordTerminator(args)
+	for _, record := range records {
+		if _, err := io.WriteString(w, record+terminator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printValues prints the value of each environment variable as a record. If keys were given with --key,
+// only their values are printed in the order requested, and keys that are not defined are an error.
+func printValues(w io.Writer, sortedEnvVars []string, args Args) error {
+	values := make([]string, 0, len(sortedEnvVars))
+	if len(args.Keys) == 0 {
+		for _, v := range sortedEnvVars {
+			_, value, _ := strings.Cut(v, "=")
+			values = append(values, value)
+		}
+	}
+	for _, key := range args.Keys {
+		value, ok := lookupEnvVar(sortedEnvVars, key)
+		if !ok {
+			return fmt.Errorf("%s is not defined", key)
+		}
+		values = append(values, value)
+	}
+	return printRecords(w, values, args)
+}
+
+// lookupEnvVar returns the value of key from a sorted KEY=VALUE slice.
+func lookupEnvVar(sortedEnvVars []string, key string) (string, bool) {
+	for _, v := range sortedEnvVars {
+		k, value, _ := strings.Cut(v, "=")
+		if k == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// printGitHubMatrix prints a GitHub Actions strategy.matrix object containing every combination
+// of the comma-separated values of the variables given with --matrix-vars.
+func printGitHubMatrix(w io.Writer, sortedEnvVars []string, args Args) error {
+	var matrixVars []string
+	for _, name := range strings.Split(args.MatrixVars, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			matrixVars = append(matrixVars, name)
+		}
+	}
+	if len(matrixVars) == 0 {
+		return fmt.Errorf("github-matrix format requires --matrix-vars")
+	}
+
+	combinations := []map[string]string{{}}
+	for _, name := range matrixVars {
+		value, ok := lookupEnvVar(sortedEnvVars, name)
+		if !ok {
+			return fmt.Errorf("matrix variable %s is not defined", name)
+		}
+
+		var expanded []map[string]string
+		for _, combination := range combinations {
+			for _, item := range strings.Split(value, ",") {
+				next := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					next[k] = v
+				}
+				next[name] = strings.TrimSpace(item)
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	return json.NewEncoder(w).Encode(map[string][]map[string]string{"include": combinations})
+}
+
+// printGitHubActions prints environment variables in the format of the $GITHUB_ENV file of GitHub Actions.
+// Multiline values use the heredoc syntax with a random delimiter, like the actions toolkit does.
+func printGitHubActions(w io.Writer, sortedEnvVars []string, _ Args) error {
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		if !strings.ContainsAny(value, "\r\n") {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", key, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		delimiter, err := heredocDelimiter()
+		if err != nil {
+			return err
+		}
+		if strings.Contains(value, delimiter) {
+			return fmt.Errorf("%s: value contains the heredoc delimiter", key)
+		}
+		if _, err := fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", key, delimiter, value, delimiter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heredocDelimiter returns a random delimiter for multiline values in the $GITHUB_ENV file.
+func heredocDelimiter() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "ghadelimiter_" + hex.EncodeToString(b), nil
+}
+
+// consulKVPair is a single entry of the format read by `consul kv import`.
+type consulKVPair struct {
+	Key   string `json:"key"`
+	Flags int    `json:"flags"`
+	Value string `json:"value"`
+}
+
+// printConsulKV prints environment variables as a Consul KV import document. Keys are prefixed
+// with --consul-prefix and values are base64-encoded as Consul requires.
+func printConsulKV(w io.Writer, sortedEnvVars []string, args Args) error {
+	pairs := make([]consulKVPair, 0, len(sortedEnvVars))
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		pairs = append(pairs, consulKVPair{
+			Key:   args.ConsulPrefix + key,
+			Value: base64.StdEncoding.EncodeToString([]byte(value)),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(pairs)
+}
+
+var xcconfigKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// printXcconfig prints environment variables as an Xcode build configuration file,
+// preceded by the #include directives given with --xcconfig-include.
+func printXcconfig(w io.Writer, sortedEnvVars []string, args Args) error {
+	for _, include := range args.XcconfigIncludes {
+		if _, err := fmt.Fprintf(w, "#include \"%s\"\n", include); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		if !xcconfigKey.MatchString(key) {
+			return fmt.Errorf("%s is not a valid Xcode build setting name", key)
+		}
+
+		// Escape $( to keep Xcode from substituting build settings, and break up // which
+		// would otherwise start a comment (e.g. in URLs) with an empty substitution.
+		value = strings.ReplaceAll(value, "$(", "$$(")
+		value = strings.ReplaceAll(value, "//", "/$()/")
+
+		if _, err := fmt.Fprintf(w, "%s = %s\n", key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hclEscaper escapes values for HCL quoted string literals. Besides the usual escape sequences,
+// the template sequences ${ and %{ are escaped to keep Terraform from interpolating them.
+var hclEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{")
+
+// printTfvars prints environment variables as Terraform variable definitions for a .tfvars file.
+func printTfvars(w io.Writer, sortedEnvVars []string, _ Args) error {
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		if _, err := fmt.Fprintf(w, "%s = \"%s\"\n", key, hclEscaper.Replace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printJSON prints environment variables as a JSON object mapping keys to values.
+func printJSON(w io.Writer, sortedEnvVars []string, _ Args) error {
+	envVars := make(map[string]string, len(sortedEnvVars))
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		envVars[key] = value
+	}
+
+	// encoding/json sorts map keys, which keeps the output stable
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(envVars)
+}
+
+// k8sObject is a Kubernetes object holding string data, such as a ConfigMap or Secret.
+type k8sObject struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sMetadata struct {
+	Name string `yaml:"name"`
+}
+
+// printConfigMap prints environment variables as a Kubernetes ConfigMap named by --name,
+// which can be passed to kubectl apply.
+func printConfigMap(w io.Writer, sortedEnvVars []string, args Args) error {
+	return printK8sObject(w, sortedEnvVars, args, k8sObject{Kind: "ConfigMap"}, func(value string) string {
+		return value
+	})
+}
+
+// printSecret prints environment variables as a Kubernetes Secret of the type given with --secret-type,
+// with base64-encoded values.
+func printSecret(w io.Writer, sortedEnvVars []string, args Args) error {
+	return printK8sObject(w, sortedEnvVars, args, k8sObject{Kind: "Secret", Type: args.SecretType}, func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	})
+}
+
+// printK8sObject prints obj named by --name as YAML, with the environment variables encoded by encode as data.
+func printK8sObject(w io.Writer, sortedEnvVars []string, args Args, obj k8sObject, encode func(string) string) error {
+	if args.Name == "" {
+		return fmt.Errorf("%s format requires --name", args.Format)
+	}
+
+	obj.APIVersion = "v1"
+	obj.Metadata.Name = args.Name
+	obj.Data = make(map[string]string, len(sortedEnvVars))
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		obj.Data[key] = encode(value)
+	}
+
+	// yaml sorts map keys, which keeps the output stable
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(obj); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// printDotenv prints environment variables as a normalized env file. Every value is quoted, in single
+// quotes unless it contains single quotes or references, and comments are left out. Values containing
+// `$` are put in backticks, so loading the file doesn't expand them again; with --no-expand references
+// are kept in double quotes instead.
+func printDotenv(w io.Writer, sortedEnvVars []string, args Args) error {
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		quoted, err := envparse.Quote(key, value, !args.NoExpand || args.literals[key])
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", key, quoted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printAnsibleVars prints environment variables as a flat YAML mapping for Ansible's vars_files. Values
+// are always double-quoted, so YAML-special characters are taken literally. Values containing Jinja2
+// delimiters are tagged !unsafe to keep Ansible from templating them.
+func printAnsibleVars(w io.Writer, sortedEnvVars []string, _ Args) error {
+	if _, err := fmt.Fprintln(w, "---"); err != nil {
+		return err
+	}
+	for _, v := range sortedEnvVars {
+		key, value, _ := strings.Cut(v, "=")
+		tag := ""
+		if strings.Contains(value, "{{") || strings.Contains(value, "{%") || strings.Contains(value, "{#") {
+			tag = "!unsafe "
+		}
+		// Go escape sequences are a subset of those of YAML double-quoted scalars
+		if _, err := fmt.Fprintf(w, "%s: %s%s\n", key, tag, strconv.Quote(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
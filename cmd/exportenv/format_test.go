@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// formatTest is a case of a formatter test: the variables given as KEY=VALUE pairs and the output.
+type formatTest struct {
+	name    string
+	envVars []string
+	args    Args
+	want    string
+}
+
+// runFormatTests runs the cases against the formatter f.
+func runFormatTests(t *testing.T, f formatter, tests []formatTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := f(&buf, tt.envVars, tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintExportableEnvVars(t *testing.T) {
+	runFormatTests(t, printExportableEnvVars, []formatTest{
+		{name: "plain", envVars: []string{"A=b"}, want: "export A=\"b\"\n"},
+		{name: "empty", envVars: []string{"A="}, want: "export A=\"\"\n"},
+		{name: "spaces", envVars: []string{"A=b c"}, want: "export A=\"b c\"\n"},
+		{name: "double quotes", envVars: []string{`A=say "hi"`}, want: "export A=\"say \\\"hi\\\"\"\n"},
+		{name: "equals sign", envVars: []string{"A=b=c"}, want: "export A=\"b=c\"\n"},
+		{
+			name:    "shell escape",
+			envVars: []string{"A=$HOME 'x'\n"},
+			args:    Args{ShellEscape: true},
+			want:    "export A=$'$HOME \\'x\\'\\n'\n",
+		},
+		{
+			name:    "shell escape of safe value",
+			envVars: []string{"A=b c"},
+			args:    Args{ShellEscape: true},
+			want:    "export A=\"b c\"\n",
+		},
+		{name: "unset", envVars: []string{"A=b"}, args: Args{Unset: []string{"OLD"}}, want: "unset OLD\nexport A=\"b\"\n"},
+	})
+}
+
+func TestPrintPowerShellEnvVars(t *testing.T) {
+	runFormatTests(t, printPowerShellEnvVars, []formatTest{
+		{name: "plain", envVars: []string{"A=b"}, want: "$env:A = \"b\"\n"},
+		{name: "spaces", envVars: []string{"A=b c"}, want: "$env:A = \"b c\"\n"},
+		{name: "double quotes", envVars: []string{`A=say "hi"`}, want: "$env:A = \"say `\"hi`\"\"\n"},
+		{name: "backticks", envVars: []string{"A=a`b"}, want: "$env:A = \"a``b\"\n"},
+		{name: "dollar", envVars: []string{"A=$HOME"}, want: "$env:A = \"`$HOME\"\n"},
+		{name: "control characters", envVars: []string{"A=a\tb\r\nc"}, want: "$env:A = \"a`tb`r`nc\"\n"},
+		{name: "single quotes", envVars: []string{"A='b'"}, want: "$env:A = \"'b'\"\n"},
+		{
+			name:    "unset",
+			envVars: []string{"A=b"},
+			args:    Args{Unset: []string{"OLD"}},
+			want:    "Remove-Item Env:OLD -ErrorAction SilentlyContinue\n$env:A = \"b\"\n",
+		},
+	})
+}
+
+func TestPrintCmdEnvVars(t *testing.T) {
+	runFormatTests(t, printCmdEnvVars, []formatTest{
+		{name: "plain", envVars: []string{"A=b"}, want: "SET \"A=b\"\n"},
+		{name: "spaces", envVars: []string{"A=b c"}, want: "SET \"A=b c\"\n"},
+		{name: "percent", envVars: []string{"A=100%"}, want: "SET \"A=100%%\"\n"},
+		{name: "reference", envVars: []string{"A=%PATH%"}, want: "SET \"A=%%PATH%%\"\n"},
+		{name: "operators", envVars: []string{"A=a & b | c > d"}, want: "SET \"A=a & b | c > d\"\n"},
+		{name: "unset", envVars: []string{"A=b"}, args: Args{Unset: []string{"OLD"}}, want: "SET OLD=\nSET \"A=b\"\n"},
+	})
+}
+
+func TestPrintCmdEnvVarsMultiline(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printCmdEnvVars(&buf, []string{"A=a\nb"}, Args{}); err == nil {
+		t.Error("printCmdEnvVars() succeeded, want an error for the multiline value")
+	}
+}
+
+func TestShellFormatters(t *testing.T) {
+	for shell, want := range map[string]string{
+		"bash": "export A=\"b\"\n",
+		"sh":   "export A=\"b\"\n",
+		"zsh":  "export A=\"b\"\n",
+		"fish": "set -x A \"b\"\n",
+		"pwsh": "$env:A = \"b\"\n",
+		"cmd":  "SET \"A=b\"\n",
+	} {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printShellEnvVars(&buf, []string{"A=b"}, Args{Shell: shell}); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != want {
+				t.Errorf("got %q, want %q", buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestPrintFishEnvVars(t *testing.T) {
+	runFormatTests(t, printFishEnvVars, []formatTest{
+		{name: "plain", envVars: []string{"A=b"}, want: "set -x A \"b\"\n"},
+		{name: "empty", envVars: []string{"A="}, want: "set -x A \"\"\n"},
+		{name: "spaces", envVars: []string{"A=b c"}, want: "set -x A \"b c\"\n"},
+		{name: "dollar", envVars: []string{"A=$HOME/x"}, want: "set -x A \"\\$HOME/x\"\n"},
+		{name: "parentheses", envVars: []string{"A=(echo hi)"}, want: "set -x A \"(echo hi)\"\n"},
+		{name: "command substitution", envVars: []string{"A=$(echo hi)"}, want: "set -x A \"\\$(echo hi)\"\n"},
+		{name: "backslash", envVars: []string{`A=C:\dir`}, want: "set -x A \"C:\\\\dir\"\n"},
+		{name: "double quotes", envVars: []string{`A=say "hi"`}, want: "set -x A \"say \\\"hi\\\"\"\n"},
+		{name: "single quotes", envVars: []string{"A='b'"}, want: "set -x A \"'b'\"\n"},
+		{name: "unset", envVars: []string{"A=b"}, args: Args{Unset: []string{"OLD"}}, want: "set -e OLD\nset -x A \"b\"\n"},
+	})
+}
+
+func TestPrintExportableEnvVarsExportKeyword(t *testing.T) {
+	envVars := []string{"A=b", "C=d e"}
+	runFormatTests(t, printExportableEnvVars, []formatTest{
+		{name: "export keyword", envVars: envVars, want: "export A=\"b\"\nexport C=\"d e\"\n"},
+		{name: "no export keyword", envVars: envVars, args: Args{NoExportKeyword: true}, want: "A=\"b\"\nC=\"d e\"\n"},
+		{
+			name:    "no export keyword with shell escape",
+			envVars: []string{"A=$x"},
+			args:    Args{NoExportKeyword: true, ShellEscape: true},
+			want:    "A=$'$x'\n",
+		},
+		{
+			name:    "no export keyword keeps unset",
+			envVars: []string{"A=b"},
+			args:    Args{NoExportKeyword: true, Unset: []string{"OLD"}},
+			want:    "unset OLD\nA=\"b\"\n",
+		},
+	})
+}
+
+func TestPrintDotenv(t *testing.T) {
+	runFormatTests(t, printDotenv, []formatTest{
+		{name: "plain", envVars: []string{"A=b c"}, want: "A='b c'\n"},
+		{name: "single quotes", envVars: []string{"A=it's"}, want: "A=\"it's\"\n"},
+		{name: "dollar", envVars: []string{"A=$5 ${B}"}, want: "A=`$5 ${B}`\n"},
+		{name: "no expand", envVars: []string{"A=${B}/x"}, args: Args{NoExpand: true}, want: "A=\"${B}/x\"\n"},
+		{
+			name:    "no expand literal",
+			envVars: []string{"A=${B}/x"},
+			args:    Args{NoExpand: true, literals: map[string]bool{"A": true}},
+			want:    "A=`${B}/x`\n",
+		},
+	})
+}
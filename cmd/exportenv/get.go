@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// GetArgs are the arguments of the get subcommand.
+type GetArgs struct {
+	EnvFiles      []string `arg:"--env-file,separate" help:"Paths to the .env files, processed in the order given"`
+	Override      bool     `arg:"-o,--override" help:"Override variables from previous files if they already exist"`
+	NoExpand      bool     `arg:"--no-expand" help:"Disable variable expansion"`
+	IgnoreMissing bool     `arg:"--ignore-missing" help:"Skip env files that do not exist"`
+	Default       *string  `arg:"--default" help:"Value printed if the variable is not defined"`
+	Format        string   `arg:"--format" default:"text" help:"Output format: text, json"`
+	Key           string   `arg:"positional,required" help:"Name of the variable to print"`
+}
+
+// getResult is the output of get --format json.
+type getResult struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// runGet runs the get subcommand, which prints the value of a single variable from the env files.
+// It returns 1 if the variable is not defined and no default is given.
+func runGet(argv []string) int {
+	var args GetArgs
+	parser, code, ok := parseSubcommand("exportenv get", &args, argv)
+	if !ok {
+		return code
+	}
+	if args.Format != "text" && args.Format != "json" {
+		return usageError(parser, fmt.Sprintf("unknown output format %q", args.Format))
+	}
+
+	envVars, err := envparse.Load(args.EnvFiles,
+		envparse.WithOverride(args.Override),
+		envparse.WithNoExpand(args.NoExpand),
+		envparse.WithIgnoreMissing(args.IgnoreMissing),
+		envparse.WithWarningHandler(func(issue envparse.Issue) {
+			fmt.Fprintln(os.Stderr, issue)
+		}),
+	)
+	if err != nil {
+		slog.Error("Error loading env files", slog.Any("error", err))
+		return 1
+	}
+
+	value, ok := envVars[args.Key]
+	if !ok {
+		if args.Default == nil {
+			slog.Error("Variable is not defined", slog.String("key", args.Key))
+			return 1
+		}
+		value = *args.Default
+	}
+
+	if args.Format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		err = encoder.Encode(getResult{Key: args.Key, Value: value})
+	} else {
+		_, err = fmt.Println(value)
+	}
+	if err != nil {
+		slog.Error("Error writing output", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestRunGet(t *testing.T) {
+	base := writeEnvFile(t, "base.env", "HOST=localhost\nURL=\"http://${HOST}\"\n")
+	local := writeEnvFile(t, "local.env", "HOST=example.com\n")
+
+	tests := []struct {
+		name     string
+		argv     []string
+		wantCode int
+		want     string
+	}{
+		{name: "defined", argv: []string{"--env-file", base, "HOST"}, want: "localhost\n"},
+		{name: "expanded", argv: []string{"--env-file", base, "URL"}, want: "http://localhost\n"},
+		{name: "no expand", argv: []string{"--env-file", base, "--no-expand", "URL"}, want: "http://${HOST}\n"},
+		{name: "first file wins", argv: []string{"--env-file", base, "--env-file", local, "HOST"}, want: "localhost\n"},
+		{name: "override", argv: []string{"--env-file", base, "--env-file", local, "--override", "HOST"}, want: "example.com\n"},
+		{name: "json", argv: []string{"--env-file", base, "--format", "json", "HOST"}, want: "{\"key\":\"HOST\",\"value\":\"localhost\"}\n"},
+		{name: "undefined", argv: []string{"--env-file", base, "PORT"}, wantCode: 1},
+		{name: "default", argv: []string{"--env-file", base, "--default", "8080", "PORT"}, want: "8080\n"},
+		{name: "missing file", argv: []string{"--env-file", base + ".missing", "HOST"}, wantCode: 1},
+		{name: "unknown format", argv: []string{"--env-file", base, "--format", "yaml", "HOST"}, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := runSubcommand(t, runGet, tt.argv...)
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
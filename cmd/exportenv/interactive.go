@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// exampleFile is the template for --interactive, each of its keys becomes a question.
+const exampleFile = ".env.example"
+
+// prompter asks questions on stderr and reads the answers from stdin, so the prompts don't end up
+// in redirected output.
+type prompter struct {
+	scanner *bufio.Scanner
+	w       io.Writer
+}
+
+// ask prints the question and returns the answer, or def if the answer is empty. With hidden the
+// answer is not echoed if stdin is a terminal. ok is false once stdin is exhausted.
+func (p prompter) ask(question, def string, hidden bool) (answer string, ok bool) {
+	if def != "" {
+		question += " [" + def + "]"
+	}
+	fmt.Fprint(p.w, question+": ")
+	if hidden && setEcho(false) {
+		defer func() {
+			setEcho(true)
+			// The newline typed by the user wasn't echoed either
+			fmt.Fprintln(p.w)
+		}()
+	}
+	if !p.scanner.Scan() {
+		return "", false
+	}
+	if answer = strings.TrimSpace(p.scanner.Text()); answer == "" {
+		answer = def
+	}
+	return answer, true
+}
+
+// confirm asks a yes/no question, returning def for an empty answer.
+func (p prompter) confirm(question string, def bool) (yes, ok bool) {
+	choices := " (y/N)"
+	if def {
+		choices = " (Y/n)"
+	}
+	answer, ok := p.ask(question+choices, "", false)
+	if !ok || answer == "" {
+		return def, ok
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), true
+}
+
+// setEcho turns the terminal echo of stdin on or off and reports whether it succeeded. It does nothing
+// if stdin isn't a terminal.
+func setEcho(on bool) bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run() == nil
+}
+
+// interactive prompts for variables and appends each of them to the env file at path as soon as it is
+// answered. It returns the exit code exportenv should exit with. The keys of .env.example are asked for
+// in order, with its values as defaults; without an example file keys are asked for until an empty one
+// is entered. Values of keys that look like secrets are not echoed.
+func interactive(path string) int {
+	template, err := envparse.LoadEntries([]string{exampleFile}, envparse.WithNoExpand(true), envparse.WithIgnoreMissing(true))
+	if err != nil {
+		slog.Error("Error loading example file", slog.Any("error", err))
+		return 1
+	}
+
+	// The file may contain secrets, so it is only readable by the current user
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		slog.Error("Error opening output file", slog.Any("error", err))
+		return 1
+	}
+	defer f.Close()
+
+	p := prompter{scanner: bufio.NewScanner(os.Stdin), w: os.Stderr}
+	for i := 0; len(template) == 0 || i < len(template); i++ {
+		var key, def string
+		if len(template) > 0 {
+			key, def = template[i].Key, template[i].Value
+			fmt.Fprintf(p.w, "%s\n", key)
+		} else {
+			var ok bool
+			if key, ok = p.ask("Key (empty to finish)", "", false); !ok || key == "" {
+				break
+			}
+			if !variableName.MatchString(key) {
+				fmt.Fprintf(p.w, "Invalid variable name %q\n", key)
+				continue
+			}
+		}
+		secret := looksSecret(key, def)
+		if def == secretPlaceholder {
+			def = ""
+		}
+
+		value, ok := p.ask("Value", def, secret)
+		if !ok {
+			break
+		}
+		quote, ok := p.confirm("Quote the value?", value != "")
+		if !ok {
+			break
+		}
+		line, err := envLine(key, value, quote)
+		if err != nil {
+			slog.Error("Error formatting variable", slog.Any("error", err))
+			return 1
+		}
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			slog.Error("Error writing output file", slog.Any("error", err))
+			return 1
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		slog.Error("Error reading input", slog.Any("error", err))
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		slog.Error("Error writing output file", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+// envLine returns the KEY=VALUE line for an env file. Without quote the value is only quoted if the
+// parser would read it differently otherwise. References in the value are expanded when loading the file.
+func envLine(key, value string, quote bool) (string, error) {
+	if !quote {
+		parsed, err := envparse.Parse(strings.NewReader(key + "=" + value + "\n"))
+		if err == nil && len(parsed) == 1 && parsed[key] == value {
+			return key + "=" + value, nil
+		}
+	}
+	quoted, err := envparse.Quote(key, value, false)
+	if err != nil {
+		return "", err
+	}
+	return key + "=" + quoted, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrompter(t *testing.T) {
+	var out bytes.Buffer
+	p := prompter{scanner: bufio.NewScanner(strings.NewReader("  value \n\nyes\n\n")), w: &out}
+
+	if answer, ok := p.ask("Value", "", false); !ok || answer != "value" {
+		t.Errorf("ask = %q, %t, want %q", answer, ok, "value")
+	}
+	if answer, ok := p.ask("Value", "default", false); !ok || answer != "default" {
+		t.Errorf("ask with empty answer = %q, %t, want the default", answer, ok)
+	}
+	if yes, ok := p.confirm("Quote the value?", false); !ok || !yes {
+		t.Errorf("confirm = %t, %t, want yes", yes, ok)
+	}
+	if yes, ok := p.confirm("Quote the value?", false); !ok || yes {
+		t.Errorf("confirm with empty answer = %t, %t, want the default no", yes, ok)
+	}
+	if _, ok := p.ask("Value", "", false); ok {
+		t.Error("ask succeeded after the end of the input")
+	}
+
+	want := "Value: Value [default]: Quote the value? (y/N): Quote the value? (y/N): Value: "
+	if out.String() != want {
+		t.Errorf("prompts = %q, want %q", out.String(), want)
+	}
+}
+
+func TestEnvLine(t *testing.T) {
+	tests := []struct {
+		value string
+		quote bool
+		want  string
+	}{
+		{value: "plain", want: "A=plain"},
+		{value: "plain", quote: true, want: "A='plain'"},
+		{value: "a # b", want: "A='a # b'"},
+		{value: "${HOST}/x", quote: true, want: `A="${HOST}/x"`},
+		{value: "", want: "A="},
+	}
+	for _, tt := range tests {
+		got, err := envLine("A", tt.value, tt.quote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("envLine(%q, %t) = %q, want %q", tt.value, tt.quote, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// LintArgs are the arguments of the lint subcommand.
+type LintArgs struct {
+	Disable []string `arg:"--disable,separate" placeholder:"RULE" help:"Skip a rule, can be repeated"`
+	Files   []string `arg:"positional" help:"Env files to check [default: .env]"`
+}
+
+// Rules checked by lint on top of the rules of envparse.Validate.
+const (
+	ruleUnquotedSecrets    = "unquoted-secrets"
+	ruleLongValues         = "long-values"
+	ruleKeyNames           = "key-names"
+	ruleCRLF               = "crlf"
+	ruleTrailingWhitespace = "trailing-whitespace"
+	ruleEmptyFile          = "empty-file"
+)
+
+// lintRules are the rules accepted by lint --disable.
+var lintRules = []string{
+	envparse.RuleSyntax,
+	envparse.RuleDuplicateKeys,
+	envparse.RulePermissions,
+	envparse.RuleUndefinedReferences,
+	ruleUnquotedSecrets,
+	ruleLongValues,
+	ruleKeyNames,
+	ruleCRLF,
+	ruleTrailingWhitespace,
+	ruleEmptyFile,
+}
+
+// maxValueLength is the length above which values are reported by the long-values rule.
+const maxValueLength = 1024
+
+// assignment matches the key of a line assigning a value, including keys that are not valid variable names.
+var assignment = regexp.MustCompile(`^\s*(?:export\s+)?([^\s=#]+)\s*=`)
+
+// runLint runs the lint subcommand, which checks env files against a set of rules and prints the issues
+// to stdout. It returns 1 if any issue is found.
+func runLint(argv []string) int {
+	var args LintArgs
+	parser, code, ok := parseSubcommand("exportenv lint", &args, argv)
+	if !ok {
+		return code
+	}
+	for _, rule := range args.Disable {
+		if !slices.Contains(lintRules, rule) {
+			return usageError(parser, fmt.Sprintf("unknown rule %q, expected one of %s", rule, strings.Join(lintRules, ", ")))
+		}
+	}
+	if len(args.Files) == 0 {
+		args.Files = []string{envparse.DefaultFile}
+	}
+	for _, file := range args.Files {
+		if file == envparse.Stdin || strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+			return usageError(parser, fmt.Sprintf("%s: only local files can be linted", file))
+		}
+	}
+
+	issues, err := lint(args.Files)
+	if err != nil {
+		slog.Error("Error linting env files", slog.Any("error", err))
+		return 1
+	}
+
+	exitCode := 0
+	for _, issue := range issues {
+		if slices.Contains(args.Disable, issue.Rule) {
+			continue
+		}
+		fmt.Printf("%s [%s]\n", issue, issue.Rule)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// lint returns the issues found by envparse.Validate and the lint rules in the files, ordered by file and line.
+func lint(files []string) ([]envparse.Issue, error) {
+	var issues []envparse.Issue
+	collect := envparse.WithWarningHandler(func(issue envparse.Issue) {
+		issues = append(issues, issue)
+	})
+	validated, err := envparse.Validate(files, collect)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, validated...)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(string(content), "\n")
+		entries, err := envparse.LoadEntries([]string{file}, envparse.WithNoExpand(true))
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, lintFile(file, lines, entries)...)
+	}
+
+	// Syntax errors caused by invalid key names are reported by the key-names rule instead
+	issues = slices.DeleteFunc(issues, func(issue envparse.Issue) bool {
+		return issue.Rule == envparse.RuleSyntax && slices.ContainsFunc(issues, func(other envparse.Issue) bool {
+			return other.Rule == ruleKeyNames && other.File == issue.File && other.Line == issue.Line
+		})
+	})
+	slices.SortStableFunc(issues, func(a, b envparse.Issue) int {
+		if a.File != b.File {
+			return slices.Index(files, a.File) - slices.Index(files, b.File)
+		}
+		return a.Line - b.Line
+	})
+	return issues, nil
+}
+
+// lintFile checks the lines of a single file and the variables defined in it.
+func lintFile(file string, lines []string, entries []envparse.Entry) []envparse.Issue {
+	var issues []envparse.Issue
+	add := func(line int, rule, format string, a ...any) {
+		issues = append(issues, envparse.Issue{
+			File:     file,
+			Line:     line,
+			Rule:     rule,
+			Severity: envparse.SeverityWarning,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	// Only the first CRLF line ending is reported, a file has them everywhere or in few places
+	if i := slices.IndexFunc(lines, func(line string) bool { return strings.HasSuffix(line, "\r") }); i >= 0 {
+		add(i+1, ruleCRLF, "Windows line ending (CRLF), use LF for portability: shells sourcing the file keep the carriage return in values")
+	}
+	for i, line := range lines {
+		matches := assignment.FindStringSubmatch(line)
+		if matches != nil && !variableName.MatchString(matches[1]) {
+			add(i+1, ruleKeyNames, "invalid key name %q, expected letters, digits and underscores", matches[1])
+		}
+	}
+
+	for _, e := range entries {
+		// Variables of included files are checked when linting these files
+		if e.File != file || e.Line < 1 || e.Line > len(lines) {
+			continue
+		}
+		line := strings.TrimSuffix(lines[e.Line-1], "\r")
+		if strings.TrimRight(line, " \t") != line {
+			add(e.Line, ruleTrailingWhitespace, "trailing whitespace after the value of %s", e.Key)
+		}
+		if len(e.Value) > maxValueLength {
+			add(e.Line, ruleLongValues, "value of %s is %d characters long, more than %d", e.Key, len(e.Value), maxValueLength)
+		}
+		if e.Value != "" && looksSecret(e.Key, e.Value) && !quotedDefinition(line) {
+			add(e.Line, ruleUnquotedSecrets, "%s looks like a secret (%s) and should be quoted", e.Key, secretReason(e.Key, e.Value))
+		}
+	}
+	if len(entries) == 0 {
+		add(0, ruleEmptyFile, "file defines no variables")
+	}
+	return issues
+}
+
+// quotedDefinition reports whether the value assigned on a line starts with a quote.
+func quotedDefinition(line string) bool {
+	_, value, _ := strings.Cut(line, "=")
+	value = strings.TrimSpace(value)
+	return value != "" && strings.ContainsRune("\"'`", rune(value[0]))
+}
@@ -1,85 +1,575 @@
 package main
 
 import (
-	"bufio"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
-	"regexp"
-	"sort"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alexflint/go-arg"
+	"github.com/cbrgm/exportenv/pkg/envparse"
 )
 
 type Args struct {
-	EnvFiles []string `arg:"--env-file,separate" help:"Paths to the .env files, processed in the order given"`
-	NoExpand bool     `arg:"--no-expand" help:"Disable variable expansion"`
-	Override bool     `arg:"-o,--override" help:"Override variables from previous files if they already exist"`
-	Vars     []string `arg:"-v,--var,separate" help:"Set variables from command line in the form KEY=VALUE"`
-	Cmd      []string `arg:"positional" help:"Command to execute with the environment variables"`
+	EnvFiles            []string      `arg:"--env-file,separate" help:"Paths to the .env files, processed in the order given"`
+	EnvDirs             []string      `arg:"--env-dir,separate" help:"Load all env files of a directory in lexicographic order, before the --env-file files"`
+	EnvDirExt           string        `arg:"--env-dir-ext" default:".env" help:"Extension of the files loaded from --env-dir, empty for files without extension"`
+	EnvFileToken        string        `arg:"--env-file-token,env:EXPORTENV_ENV_FILE_TOKEN" help:"Bearer token used to fetch env files from URLs"`
+	EnvFileTimeout      time.Duration `arg:"--env-file-timeout" default:"30s" help:"Timeout for fetching an env file from a URL"`
+	EnvFileRetries      int           `arg:"--env-file-retries" default:"3" help:"Number of retries after transient errors when fetching an env file from a URL"`
+	NoExpand            bool          `arg:"--no-expand" help:"Disable variable expansion"`
+	Override            bool          `arg:"-o,--override" help:"Override variables from previous files if they already exist"`
+	Vars                []string      `arg:"-v,--var,separate" help:"Set variables from command line in the form KEY=VALUE"`
+	Format              string        `arg:"--format" default:"export" help:"Output format: export, json, github-matrix, consul-kv, xcconfig, k8s-configmap, k8s-secret, tfvars, github-actions, dotenv"`
+	Shell               string        `arg:"--shell" default:"bash" help:"Shell syntax of the export format: bash, sh, zsh, fish, pwsh, cmd"`
+	ImportFromShell     string        `arg:"--import-from-shell" help:"Run a shell script and import the variables it sets, after the env files"`
+	MatrixVars          string        `arg:"--matrix-vars" help:"Comma-separated variables whose comma-separated values are combined into a GitHub Actions matrix"`
+	ConsulPrefix        string        `arg:"--consul-prefix" help:"Key prefix used by the consul-kv format, e.g. myapp/config/"`
+	XcconfigIncludes    []string      `arg:"--xcconfig-include,separate" help:"Files to #include at the top of the xcconfig format"`
+	CleanEnv            bool          `arg:"--clean-env" help:"Do not inherit the current environment when executing the command"`
+	Require             []string      `arg:"--require,separate" help:"Abort if the variable is not defined or empty after loading"`
+	IgnoreMissing       bool          `arg:"--ignore-missing" help:"Skip env files that do not exist"`
+	Timeout             time.Duration `arg:"--timeout" help:"Kill the command if it runs longer than this duration, e.g. 30s or 1h30m"`
+	Validate            bool          `arg:"--validate" help:"Check the env files for errors without printing or executing anything"`
+	Diff                bool          `arg:"--diff" help:"Show how the loaded variables differ from the current environment"`
+	Strict              bool          `arg:"--strict" help:"Reject shell-style export KEY=VALUE lines and abort on malformed lines in env files"`
+	Unset               []string      `arg:"--unset,separate" help:"Remove the variable from the environment of the command"`
+	NoBackslashContinue bool          `arg:"--no-backslash-continue" help:"Keep trailing backslashes instead of joining the line with the next one"`
+	Prefix              string        `arg:"--prefix" help:"Only export variables whose key starts with this prefix"`
+	StripPrefix         string        `arg:"--strip-prefix" help:"Remove this prefix from the keys that start with it"`
+	OutputFile          string        `arg:"--output-file" help:"Write the output atomically to this file instead of stdout"`
+	Profile             string        `arg:"--profile" help:"Load .env, .env.<profile> and .env.local, each overriding the previous ones"`
+	WarnOnDuplicate     bool          `arg:"--warn-on-duplicate" help:"Warn about variables ignored because a previous file already defined them"`
+	SortBy              string        `arg:"--sort-by" default:"key" help:"Output order: key, value, length, none (definition order), file (grouped by source file)"`
+	Name                string        `arg:"--name" help:"Object name used by the k8s-configmap and k8s-secret formats"`
+	SecretType          string        `arg:"--secret-type" default:"Opaque" help:"Type of the Secret created by the k8s-secret format"`
+	KeysOnly            bool          `arg:"--keys-only" help:"Print only the variable names, one per line"`
+	ValuesOnly          bool          `arg:"--values-only" help:"Print only the variable values, one per line"`
+	Keys                []string      `arg:"--key,separate" help:"Print only the value of this variable, in the order requested"`
+	NullTerminated      bool          `arg:"-0,--null-terminated" help:"Print raw KEY=VALUE records terminated by a null byte, like env -0"`
+	Exclude             []string      `arg:"--exclude,separate" help:"Do not print or pass variables whose key matches this glob pattern, e.g. AWS_*"`
+	StrictPermissions   bool          `arg:"--strict-permissions" help:"Abort if an env file is readable by other users instead of warning"`
+	NoDirectives        bool          `arg:"--no-directives" help:"Disable unset KEY and include FILE directives in env files"`
+	Watch               bool          `arg:"--watch" help:"Restart the command whenever an env file changes"`
+	WatchPollInterval   time.Duration `arg:"--watch-poll-interval" default:"1s" help:"Interval in which --watch checks the env files for changes"`
+	GracePeriod         time.Duration `arg:"--grace-period" default:"10s" help:"Time the command gets to exit after SIGTERM before it is killed"`
+	Dir                 string        `arg:"--dir" help:"Working directory of the command"`
+	EnvFileFormat       string        `arg:"--env-file-format" help:"Format of the env files: dotenv, json, yaml, toml. Detected by the file extension by default"`
+	SetIfEmpty          bool          `arg:"--set-if-empty" help:"Only load variables that are unset or empty in the current environment"`
+	LogLevel            string        `arg:"--log-level" default:"info" help:"Minimum level of log messages: debug, info, warn, error"`
+	OnlyChanged         bool          `arg:"--only-changed" help:"Only load variables that are new or differ from the current environment"`
+	Renames             []string      `arg:"--rename,separate" placeholder:"KEY=NEWKEY" help:"Rename a variable after loading; the new key replaces an existing one only with --override"`
+	UppercaseKeys       bool          `arg:"--uppercase-keys" help:"Convert all keys to upper case"`
+	LowercaseKeys       bool          `arg:"--lowercase-keys" help:"Convert all keys to lower case"`
+	AddPrefix           string        `arg:"--add-prefix" help:"Prepend this prefix to all keys, after --strip-prefix"`
+	GenerateExample     string        `arg:"--generate-example" placeholder:"FILE" help:"Write FILE.example with the keys of FILE and placeholder values, then exit"`
+	MaskValues          []string      `arg:"--mask-value,separate" placeholder:"KEY" help:"Print *** instead of the value of this variable; the command still receives the value"`
+	WarnSecrets         bool          `arg:"--warn-secrets" help:"Log a warning for every variable that looks like a secret"`
+	ShellEscape         bool          `arg:"--shell-escape" help:"Quote values with special characters as $'...' in export statements, safe for eval in bash and zsh"`
+	Verbose             bool          `arg:"--verbose" help:"Show the file and line each variable was taken from: as comments in export output, as debug logs when executing a command"`
+	NoSort              bool          `arg:"--no-sort" help:"Keep the order the variables were defined in, short for --sort-by none"`
+	GroupByFile         bool          `arg:"--group-by-file" help:"Group the variables by the file they were taken from, with a comment naming the file in export output"`
+	Limit               int           `arg:"--limit" help:"Only load the first N variables of each env file"`
+	SkipInvalid         bool          `arg:"--skip-invalid" help:"Skip malformed lines in env files silently, or with a warning if --verbose is set"`
+	InterpolationMode   string        `arg:"--interpolation-mode" default:"dollar-brace" help:"Syntax of references in values: dollar-brace (${VAR}), dollar-paren ($(VAR)), percent (%VAR%), none"`
+	Cascade             bool          `arg:"--cascade" help:"Load .env, .env.local, .env.<hostname> and .env.<hostname>.local, later files taking precedence"`
+	NoExportKeyword     bool          `arg:"--no-export-keyword" help:"Print KEY=\"VALUE\" lines without the export keyword"`
+	Interactive         bool          `arg:"--interactive" help:"Prompt for variables and append them to --output-file, then exit"`
+	AuditLog            string        `arg:"--audit-log" placeholder:"FILE" help:"Append a JSON Lines record with a redacted value for each loaded variable and the environment of the command to FILE"`
+	FromPID             int           `arg:"--from-pid" placeholder:"PID" help:"Import the environment of the process PID, after the env files (Linux and macOS)"`
+	FailOnEmpty         []string      `arg:"--fail-on-empty,separate" placeholder:"KEY" help:"Abort if the variable is defined but empty after loading"`
+	Template            string        `arg:"--template" placeholder:"FILE" help:"Render the Go template FILE with the loaded variables instead of printing them"`
+	TemplateMissing     string        `arg:"--template-missing" default:"error" help:"How --template handles undefined variables: error or ignore"`
+	Protect             []string      `arg:"--protect,separate" placeholder:"KEY" help:"Keep the first value of the variable, ignoring later files and -v"`
+	Namespace           string        `arg:"--namespace" placeholder:"NS" help:"Prefix all keys with NS_ in upper case, so they can not shadow other variables of the command"`
+	StripNamespace      string        `arg:"--strip-namespace" placeholder:"NS" help:"Only export the variables prefixed with NS_ in upper case, without the prefix"`
+	NormalizeBooleans   bool          `arg:"--normalize-booleans" help:"Rewrite boolean values such as yes, ON or 1 in the form of --bool-format"`
+	BoolFormat          string        `arg:"--bool-format" default:"true/false" help:"Form of normalized booleans, e.g. true/false, yes/no, on/off or 1/0"`
+	Base64Decode        bool          `arg:"--base64-decode" help:"Decode every value that is valid Base64 of UTF-8 text"`
+	Base64DecodeKeys    []string      `arg:"--base64-decode-key,separate" placeholder:"KEY" help:"Decode the Base64 value of the variable, failing if it is not valid Base64"`
+	URLDecode           bool          `arg:"--url-decode" help:"Percent-decode every value, e.g. %40 to @"`
+	URLDecodeKeys       []string      `arg:"--url-decode-key,separate" placeholder:"KEY" help:"Percent-decode the value of the variable"`
+	MaxValueLength      int           `arg:"--max-value-length" placeholder:"N" help:"Abort if a value is longer than N bytes after expansion"`
+	WarnMaxLength       bool          `arg:"--warn-max-length" help:"Only warn about values longer than --max-value-length"`
+	VaultPasswordFile   string        `arg:"--vault-password-file" placeholder:"FILE" help:"Decrypt Ansible Vault values of YAML files with the password in FILE"`
+	VaultPasswordEnv    string        `arg:"--vault-password-env" placeholder:"NAME" help:"Decrypt Ansible Vault values of YAML files with the password in the environment variable NAME"`
+	NoVault             bool          `arg:"--no-vault" help:"Leave Ansible Vault values encrypted"`
+	FromDockerContainer string        `arg:"--from-docker-container" placeholder:"NAME" help:"Import the environment of a Docker container, after the env files"`
+	FromShell           bool          `arg:"--from-shell" help:"Import the variables set by the startup files of the login shell in $SHELL, after the env files"`
+	Schema              string        `arg:"--schema" placeholder:"FILE" help:"Validate the variables against the JSON Schema in FILE after loading"`
+	GenerateSchema      bool          `arg:"--generate-schema" help:"Print a JSON Schema of the loaded variables as a starting point for --schema, then exit"`
+	Cmd                 []string      `arg:"positional" help:"Command to execute with the environment variables"`
+
+	// origins maps the loaded keys to the file and line their value was taken from, for --verbose
+	origins map[string]string
+	// files maps the loaded keys to the file their value was taken from, for --group-by-file
+	files map[string]string
+	// literals holds the keys of values in backticks, which --format dotenv keeps literal with --no-expand
+	literals map[string]bool
+	// schema is loaded from --schema, its descriptions are shown with --verbose
+	schema *envSchema
+}
+
+// description returns the description of the variable in --schema, if any.
+func (args Args) description(key string) string {
+	if args.schema == nil {
+		return ""
+	}
+	if p, ok := args.schema.Properties[key]; ok {
+		return p.Description
+	}
+	return ""
+}
+
+// logLevels maps the values of --log-level to slog levels.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
 }
 
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{}))
+	// Logs go to stderr, stdout is reserved for the exported variables
+	var logLevel slog.LevelVar
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel}))
 	slog.SetDefault(logger)
 
+	if run, argv, ok := lookupSubcommand(os.Args[1:]); ok {
+		os.Exit(run(argv))
+	}
+
 	var args Args
-	arg.MustParse(&args)
+	parser := arg.MustParse(&args)
+	if err := checkArgs(args); err != nil {
+		parser.Fail(err.Error())
+	}
+
+	level := logLevels[args.LogLevel]
+	if args.Verbose {
+		// The origins of the variables are logged at debug level when executing a command
+		level = min(level, slog.LevelDebug)
+	}
+	logLevel.Set(level)
+
+	if err := prepareArgs(&args); err != nil {
+		slog.Error("Invalid arguments", slog.Any("error", err))
+		os.Exit(1)
+	}
+	opts, err := loadOptions(args)
+	if err != nil {
+		slog.Error("Error reading the vault password", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	switch {
+	case args.Validate:
+		os.Exit(validate(args.EnvFiles, opts))
+	case args.GenerateExample != "":
+		os.Exit(generateExample(args.GenerateExample))
+	case args.Interactive:
+		os.Exit(interactive(args.OutputFile))
+	case args.Watch:
+		os.Exit(watch(args, opts))
+	}
 
-	// Load env files with the specified override behavior
-	envVars, err := loadEnvFiles(args.EnvFiles, args.Override)
+	entries, err := loadEntries(args, opts)
 	if err != nil {
+		// Like a shell, print the message of ${VAR:?message} as is
+		var requiredErr *envparse.RequiredError
+		if errors.As(err, &requiredErr) {
+			fmt.Fprintf(os.Stderr, "exportenv: %s\n", requiredErr)
+			os.Exit(1)
+		}
 		slog.Error("Error loading env files", slog.Any("error", err))
-		return
+		os.Exit(1)
 	}
+	os.Exit(run(args, entries))
+}
 
-	cmdVars := parseCommandLineVars(args.Vars)
-	mergeEnvVars(envVars, cmdVars)
+// loadOptions returns the options for loading the env files selected by args.
+func loadOptions(args Args) ([]envparse.Option, error) {
+	vaultPassword, err := readVaultPassword(args)
+	if err != nil {
+		return nil, err
+	}
 
-	if !args.NoExpand {
-		expandEnvVars(envVars)
+	opts := []envparse.Option{
+		envparse.WithOverride(args.Override),
+		envparse.WithNoExpand(args.NoExpand),
+		envparse.WithInterpolation(envparse.Interpolation(args.InterpolationMode)),
+		envparse.WithIgnoreMissing(args.IgnoreMissing),
+		envparse.WithStrict(args.Strict),
+		envparse.WithNoBackslashContinue(args.NoBackslashContinue),
+		envparse.WithNoDirectives(args.NoDirectives),
+		envparse.WithFormat(envparse.Format(args.EnvFileFormat)),
+		envparse.WithHTTPClient(&http.Client{Timeout: args.EnvFileTimeout}),
+		envparse.WithBearerToken(args.EnvFileToken),
+		envparse.WithRetries(args.EnvFileRetries),
+		envparse.WithVars(parseCommandLineVars(args.Vars)),
+		envparse.WithDuplicateWarnings(args.WarnOnDuplicate),
+		envparse.WithProtected(args.Protect),
+		// Ignored values of protected variables are reported with --verbose
+		envparse.WithProtectedWarnings(args.Verbose),
+		envparse.WithStrictPermissions(args.StrictPermissions),
+		envparse.WithLimit(args.Limit),
+		envparse.WithVaultPassword(vaultPassword),
+		// Skipped lines are reported with --verbose
+		envparse.WithSkipInvalid(args.SkipInvalid && !args.Verbose),
+		// Warnings go to stderr to keep the output usable with eval
+		envparse.WithWarningHandler(func(issue envparse.Issue) {
+			fmt.Fprintln(os.Stderr, issue)
+		}),
 	}
+	for _, dir := range args.EnvDirs {
+		opts = append(opts, envparse.WithDir(dir, args.EnvDirExt))
+	}
+	return opts, nil
+}
 
-	sortedEnvVars := sortEnvVars(envVars)
+// run handles the loaded variables: it prints them, writes them to --output-file or renders them
+// with --template, and executes the command if one is given. It returns the exit code exportenv
+// should exit with.
+func run(args Args, entries []envparse.Entry) int {
+	if args.AuditLog != "" {
+		if err := auditLoaded(args.AuditLog, entries, args.CleanEnv); err != nil {
+			slog.Error("Error writing audit log", slog.Any("error", err))
+			return 1
+		}
+	}
+	if args.WarnSecrets {
+		warnSecrets(entries)
+	}
+	args = withEntryDetails(args, entries)
+
+	switch {
+	case args.Diff:
+		return showDiff(envparse.ToMap(entries), args)
+	case args.Template != "":
+		return writeTemplate(envparse.ToMap(entries), args)
+	case args.GenerateSchema:
+		schema, err := generateSchema(entries, args.MaskValues)
+		if err != nil {
+			slog.Error("Error generating schema", slog.Any("error", err))
+			return 1
+		}
+		return writeOutput(schema, args)
+	}
+
+	sortedEnvVars := sortEntries(entries, args)
+	// Masked values are only hidden from the output, the command receives them unchanged
+	printedEnvVars := maskValues(sortedEnvVars, args.MaskValues)
+	format := outputFormatter(args)
+
+	// With --output-file the output is written even if a command is executed afterwards
+	if args.OutputFile != "" {
+		// The output may contain secrets, so it is only readable by the current user
+		err := writeFileAtomic(args.OutputFile, 0o600, func(w io.Writer) error {
+			return format(w, printedEnvVars, args)
+		})
+		if err != nil {
+			slog.Error("Error writing output file", slog.Any("error", err))
+			return 1
+		}
+	}
 
 	if len(args.Cmd) == 0 {
-		printExportableEnvVars(sortedEnvVars)
-		return
+		if args.OutputFile != "" {
+			return 0
+		}
+		if err := format(os.Stdout, printedEnvVars, args); err != nil {
+			slog.Error("Error writing output", slog.Any("error", err))
+			return 1
+		}
+		return 0
 	}
 
-	handleExecution(args.Cmd, sortedEnvVars)
+	if args.Verbose {
+		for _, e := range entries {
+			attrs := []any{slog.String("key", e.Key), slog.String("origin", cmp.Or(args.origins[e.Key], "unknown"))}
+			if description := args.description(e.Key); description != "" {
+				attrs = append(attrs, slog.String("description", description))
+			}
+			slog.Debug("Loaded variable", attrs...)
+		}
+	}
+	return handleExecution(args, sortedEnvVars)
 }
 
-// loadEnvFiles loads variables from multiple env files in order, using .env as a default if no files are provided.
-// If override is true, succeeding files will overwrite variables from previous files.
-func loadEnvFiles(files []string, override bool) (map[string]string, error) {
-	// Use .env as default if no files are specified
-	if len(files) == 0 {
-		files = []string{".env"}
+// withEntryDetails returns args with the details of the loaded entries that the output needs: their
+// origins for --verbose, their files for --group-by-file and which values are literal for --no-expand.
+func withEntryDetails(args Args, entries []envparse.Entry) Args {
+	if args.Verbose {
+		args.origins = entryOrigins(entries)
 	}
+	if args.GroupByFile {
+		args.files = make(map[string]string, len(entries))
+		for _, e := range entries {
+			args.files[e.Key] = e.File
+		}
+	}
+	if args.NoExpand {
+		args.literals = make(map[string]bool)
+		for _, e := range entries {
+			args.literals[e.Key] = e.Literal
+		}
+	}
+	return args
+}
 
-	envVars := make(map[string]string)
-	for _, file := range files {
-		fileVars, err := parseEnvFile(file)
+// entryOrigins returns the file and line each variable was taken from, as file:line. Variables from
+// files without line numbers have only the file, variables from other sources are left out.
+func entryOrigins(entries []envparse.Entry) map[string]string {
+	origins := make(map[string]string, len(entries))
+	for _, e := range entries {
+		switch {
+		case e.File == "":
+		case e.Line == 0:
+			origins[e.Key] = e.File
+		default:
+			origins[e.Key] = fmt.Sprintf("%s:%d", e.File, e.Line)
+		}
+	}
+	return origins
+}
+
+// shellManagedVars are maintained by the shell itself and never imported from a shell.
+var shellManagedVars = map[string]bool{"_": true, "OLDPWD": true, "PWD": true, "SHLVL": true}
+
+// importFromShell sources a shell script in a subprocess and returns the variables that differ
+// from the current environment once the script has completed.
+func importFromShell(script string) (map[string]string, error) {
+	scriptPath, err := filepath.Abs(script)
+	if err != nil {
+		return nil, err
+	}
+	shellVars, err := changedShellVars(func(output string) *exec.Cmd {
+		return exec.Command("sh", "-c", `. "$1" && env -0 > "$2"`, "sh", scriptPath, output)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %w", script, err)
+	}
+	return shellVars, nil
+}
+
+// importFromLoginShell runs the login shell of the user given by $SHELL and returns the variables
+// of its environment that differ from the current environment, such as variables exported by
+// its startup files.
+func importFromLoginShell() (map[string]string, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		return nil, errors.New("SHELL is not set")
+	}
+	// csh and tcsh only accept -l as their sole argument, their startup files are read without it
+	flags := []string{"-l", "-c"}
+	if name := filepath.Base(shell); name == "csh" || name == "tcsh" {
+		flags = []string{"-c"}
+	}
+	shellVars, err := changedShellVars(func(output string) *exec.Cmd {
+		// The quoted reference works in POSIX shells, csh and fish alike
+		cmd := exec.Command(shell, append(flags, `env -0 > "$EXPORTENV_OUTPUT"`)...)
+		cmd.Env = append(os.Environ(), "EXPORTENV_OUTPUT="+output)
+		return cmd
+	})
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %w", shell, err)
+	}
+	delete(shellVars, "EXPORTENV_OUTPUT")
+	return shellVars, nil
+}
+
+// changedShellVars runs the shell command returned by command, which must write its environment
+// with env -0 to the file output, and returns the variables that differ from the current environment.
+// The environment is written to a temporary file so the command's own output is left untouched.
+func changedShellVars(command func(output string) *exec.Cmd) (map[string]string, error) {
+	tmp, err := os.CreateTemp("", "exportenv-*.env")
+	if err != nil {
+		return nil, err
+	}
+	// nolint: errcheck
+	defer os.Remove(tmp.Name())
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	// The command's stdout goes to stderr to keep the export statements printable with eval.
+	cmd := command(tmp.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	shellVars := make(map[string]string)
+	for _, entry := range strings.Split(string(data), "\x00") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || shellManagedVars[key] {
+			continue
+		}
+		if current, exists := os.LookupEnv(key); exists && current == value {
+			continue
+		}
+		shellVars[key] = value
+	}
+	return shellVars, nil
+}
+
+// loadEntries loads the env files with the specified override behavior, checks the required variables
+// and removes the variables that are not selected by the command line flags.
+func loadEntries(args Args, opts []envparse.Option) ([]envparse.Entry, error) {
+	sources, err := sourceOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := envparse.LoadEntries(args.EnvFiles, append(slices.Clip(opts), sources...)...)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEntries(entries, args); err != nil {
+		return nil, err
+	}
+	if entries, err = transformEntries(entries, args); err != nil {
+		return nil, err
+	}
+
+	if args.schema != nil {
+		if err := args.schema.validate(entries); err != nil {
+			return nil, fmt.Errorf("variables don't match the schema %s:\n%w", args.Schema, err)
+		}
+	}
+	return entries, nil
+}
+
+// sourceOptions returns the options adding the variables imported from a shell script, the login shell,
+// a process or a Docker container, which are loaded after the env files.
+func sourceOptions(args Args) ([]envparse.Option, error) {
+	var opts []envparse.Option
+	if args.ImportFromShell != "" {
+		shellVars, err := importFromShell(args.ImportFromShell)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("importing variables from shell script: %w", err)
+		}
+		opts = append(opts, envparse.WithSource(shellVars))
+	}
+	if args.FromShell {
+		shellVars, err := importFromLoginShell()
+		if err != nil {
+			return nil, fmt.Errorf("importing variables from the login shell: %w", err)
 		}
-		for k, v := range fileVars {
-			// Set variable only if it doesn't exist or override is true
-			if override || !existsInMap(envVars, k) {
-				envVars[k] = v
+		opts = append(opts, envparse.WithSource(shellVars))
+	}
+	if args.FromPID != 0 {
+		processVars, err := processEnv(args.FromPID)
+		if err != nil {
+			return nil, fmt.Errorf("reading the environment of process %d: %w", args.FromPID, err)
+		}
+		opts = append(opts, envparse.WithSource(processVars))
+	}
+	if args.FromDockerContainer != "" {
+		containerVars, err := containerEnv(args.FromDockerContainer)
+		if err != nil {
+			return nil, fmt.Errorf("reading the environment of container %s: %w", args.FromDockerContainer, err)
+		}
+		opts = append(opts, envparse.WithSource(containerVars))
+	}
+	return opts, nil
+}
+
+// checkEntries checks the loaded variables against --require, --fail-on-empty and --max-value-length.
+// All checks are reported together, so all variables can be fixed at once.
+func checkEntries(entries []envparse.Entry, args Args) error {
+	var errs []error
+	envVars := envparse.ToMap(entries)
+	if missing := missingVars(envVars, args.Require); len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("required variables are missing or empty: %s", strings.Join(missing, ", ")))
+	}
+	if empty := emptyVars(envVars, args.FailOnEmpty); len(empty) > 0 {
+		errs = append(errs, fmt.Errorf("variables are defined but empty: %s", strings.Join(empty, ", ")))
+	}
+	if args.MaxValueLength > 0 {
+		for _, e := range entries {
+			if len(e.Value) <= args.MaxValueLength {
+				continue
 			}
+			if args.WarnMaxLength {
+				slog.Warn("Value exceeds the maximum length", slog.String("key", e.Key), slog.Int("length", len(e.Value)), slog.Int("max", args.MaxValueLength))
+				continue
+			}
+			errs = append(errs, fmt.Errorf("value of %s is %d bytes long, more than %d", e.Key, len(e.Value), args.MaxValueLength))
 		}
 	}
-	return envVars, nil
+	return errors.Join(errs...)
 }
 
-// existsInMap checks if a key exists in the map.
-func existsInMap(m map[string]string, key string) bool {
-	_, exists := m[key]
-	return exists
+// transformEntries decodes, renames and selects the variables as given by the command line flags.
+func transformEntries(entries []envparse.Entry, args Args) ([]envparse.Entry, error) {
+	var err error
+	if args.Base64Decode || len(args.Base64DecodeKeys) > 0 {
+		if entries, err = decodeBase64(entries, args.Base64Decode, args.Base64DecodeKeys); err != nil {
+			return nil, err
+		}
+	}
+	if args.URLDecode || len(args.URLDecodeKeys) > 0 {
+		entries = urlDecode(entries, args.URLDecode, args.URLDecodeKeys)
+	}
+	if args.NormalizeBooleans {
+		format, _ := parseBoolFormat(args.BoolFormat)
+		entries = normalizeBooleans(entries, format)
+	}
+
+	// Renamed keys are selected by the filters below under their new name
+	renames, _ := parseRenames(args.Renames)
+	entries = renameKeys(entries, renames, args.Override)
+	switch {
+	case args.UppercaseKeys:
+		entries, err = convertKeys(entries, strings.ToUpper)
+	case args.LowercaseKeys:
+		entries, err = convertKeys(entries, strings.ToLower)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if args.Prefix != "" {
+		entries = filterPrefix(entries, args.Prefix)
+	}
+	if args.StripPrefix != "" {
+		var replaced []string
+		entries, replaced = stripPrefix(entries, args.StripPrefix)
+		if len(replaced) > 0 {
+			slog.Warn("Variables replaced by stripping the prefix", slog.String("prefix", args.StripPrefix), slog.Any("keys", replaced))
+		}
+	}
+	if args.AddPrefix != "" {
+		entries = addPrefix(entries, args.AddPrefix)
+	}
+
+	if args.SetIfEmpty {
+		entries = withoutDefined(entries)
+	}
+	if args.OnlyChanged {
+		entries = withoutUnchanged(entries)
+	}
+
+	// Unset variables must not reach the command, even if they are defined in a file
+	entries = withoutEntries(entries, args.Unset)
+	return excludeKeys(entries, args.Exclude), nil
 }
 
 // parseCommandLineVars parses command-line variables from -v flags.
@@ -97,28 +587,78 @@ func parseCommandLineVars(vars []string) map[string]string {
 	return cmdVars
 }
 
-// mergeEnvVars merges .env variables with command-line variables (command-line takes precedence).
-func mergeEnvVars(envVars, cmdVars map[string]string) {
-	for k, v := range cmdVars {
-		envVars[k] = v
+// validate reports the issues found in the env files to stderr and returns the exit code
+// exportenv should exit with: 1 if any errors were found, 0 otherwise.
+func validate(files []string, opts []envparse.Option) int {
+	issues, err := envparse.Validate(files, opts...)
+	if err != nil {
+		slog.Error("Error validating env files", slog.Any("error", err))
+		return 1
+	}
+
+	exitCode := 0
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+		if issue.Severity == envparse.SeverityError {
+			exitCode = 1
+		}
 	}
+	return exitCode
 }
 
-// expandEnvVars performs variable expansion (e.g., ${VAR} syntax) in .env values.
-func expandEnvVars(envVars map[string]string) {
-	for key, value := range envVars {
-		envVars[key] = os.Expand(value, func(varName string) string {
-			if val, ok := envVars[varName]; ok {
-				return val
-			}
-			return ""
-		})
+// readVaultPassword returns the Ansible Vault password given with --vault-password-file or
+// --vault-password-env, or an empty string with --no-vault. Like Ansible, surrounding whitespace
+// is removed from the password file.
+func readVaultPassword(args Args) (string, error) {
+	switch {
+	case args.NoVault:
+		return "", nil
+	case args.VaultPasswordFile != "":
+		password, err := os.ReadFile(args.VaultPasswordFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(password)), nil
+	case args.VaultPasswordEnv != "":
+		password, ok := os.LookupEnv(args.VaultPasswordEnv)
+		if !ok || password == "" {
+			return "", fmt.Errorf("environment variable %s is not set", args.VaultPasswordEnv)
+		}
+		return password, nil
 	}
+	return "", nil
+}
+
+// missingVars returns the required keys that are not defined or empty.
+func missingVars(envVars map[string]string, required []string) []string {
+	var missing []string
+	for _, key := range required {
+		if envVars[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// emptyVars returns the keys that are defined with an empty value. Keys that are not defined are
+// left to missingVars.
+func emptyVars(envVars map[string]string, keys []string) []string {
+	var empty []string
+	for _, key := range keys {
+		if value, ok := envVars[key]; ok && value == "" {
+			empty = append(empty, key)
+		}
+	}
+	return empty
 }
 
 // printExportableEnvVars prints environment variables in an exportable format.
-func printExportableEnvVars(sortedEnvVars []string) {
-	for _, v := range sortedEnvVars {
+func printExportableEnvVars(w io.Writer, sortedEnvVars []string, args Args) error {
+	if err := printUnsetStatements(w, "unset %s\n", args.Unset); err != nil {
+		return err
+	}
+	group := ""
+	for i, v := range sortedEnvVars {
 		parts := strings.SplitN(v, "=", 2)
 		key := parts[0]
 		value := ""
@@ -129,172 +669,194 @@ func printExportableEnvVars(sortedEnvVars []string) {
 		// Always enclose the value in double quotes to ensure compatibility with spaces and special characters.
 		// If the value is empty, it will be output as export key="".
 		quotedValue := `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+		if args.ShellEscape && needsShellEscape(value) {
+			quotedValue = ansiCQuote(value)
+		}
+
+		// Entries are grouped by file, so a header is printed whenever the file changes
+		if file, ok := args.files[key]; ok && (i == 0 || file != group) {
+			header := fmt.Sprintf("# from %s\n", cmp.Or(file, "other sources"))
+			if i > 0 {
+				header = "\n" + header
+			}
+			if _, err := io.WriteString(w, header); err != nil {
+				return err
+			}
+			group = file
+		}
+		if description := args.description(key); description != "" && args.Verbose {
+			if _, err := fmt.Fprintf(w, "# %s\n", description); err != nil {
+				return err
+			}
+		}
+		if origin, ok := args.origins[key]; ok {
+			if _, err := fmt.Fprintf(w, "# from %s\n", origin); err != nil {
+				return err
+			}
+		}
 
 		// Print the export statement
-		fmt.Printf("export %s=%s\n", key, quotedValue)
+		statement := "export "
+		if args.NoExportKeyword {
+			statement = ""
+		}
+		if _, err := fmt.Fprintf(w, "%s%s=%s\n", statement, key, quotedValue); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// handleExecution executes the given command within the modified environment.
-func handleExecution(cmdArgs, envVars []string) {
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-	cmd.Env = append(os.Environ(), envVars...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		slog.Error("Error executing command", slog.Any("error", err))
+// withoutKeys returns the KEY=VALUE pairs of environ whose key is not in keys.
+func withoutKeys(environ, keys []string) []string {
+	filtered := make([]string, 0, len(environ))
+	for _, v := range environ {
+		key, _, _ := strings.Cut(v, "=")
+		if !slices.Contains(keys, key) {
+			filtered = append(filtered, v)
+		}
 	}
+	return filtered
 }
 
-// sortEnvVars sorts environment variables by key.
-func sortEnvVars(envVars map[string]string) []string {
-	keys := make([]string, 0, len(envVars))
-	for k := range envVars {
-		keys = append(keys, k)
+// timeoutExitCode is returned if the command exceeds --timeout, like timeout(1) does.
+const timeoutExitCode = 124
+
+// writeFileAtomic writes a file with the given permissions by writing to a temporary file in the same
+// directory and renaming it, so readers never see a partially written file.
+func writeFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
 	}
-	sort.Strings(keys)
+	// Clean up the temporary file on errors, this fails harmlessly after the rename
+	// nolint: errcheck
+	defer os.Remove(tmp.Name())
 
-	sortedEnv := make([]string, len(envVars))
-	for i, k := range keys {
-		sortedEnv[i] = k + "=" + envVars[k]
+	if err := tmp.Chmod(perm); err != nil {
+		// nolint: errcheck
+		tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		// nolint: errcheck
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
-	return sortedEnv
+	return os.Rename(tmp.Name(), path)
 }
 
-// parseEnvFile reads an env file into a map with support for comments, multiline values, and interpolation.
-func parseEnvFile(filePath string) (map[string]string, error) {
-	envVars := make(map[string]string)
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// handleExecution executes the given command within the modified environment and returns the exit code
+// exportenv should exit with. The command is killed if it runs longer than --timeout.
+// SIGINT, SIGTERM and SIGHUP are forwarded to the command. After SIGTERM the command is killed if it
+// doesn't exit within --grace-period.
+func handleExecution(args Args, envVars []string) int {
+	ctx := context.Background()
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
 	}
-	// nolint: errcheck
-	defer file.Close()
-
-	var (
-		key       string
-		value     string
-		multiline bool
-		quoteType rune
-	)
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
 
-		// Ignore comment or empty lines
-		if isCommentOrEmpty(line) {
-			continue
+	cmd := newCommand(ctx, args, envVars)
+	if args.AuditLog != "" {
+		if err := auditExec(args.AuditLog, cmd.Env); err != nil {
+			slog.Error("Error writing audit log", slog.Any("error", err))
+			return 1
 		}
-
-		// Handle multiline values continuation
-		if multiline {
-			// Check if the multiline value ends on this line
-			if strings.HasSuffix(line, string(quoteType)) {
-				// Remove trailing quote and add the line to the multiline value
-				value += "\n" + strings.TrimSuffix(line, string(quoteType))
-				// Remove any inline comment after the closing quote
-				value = removeInlineComment(value)
-				envVars[key] = value
-				multiline = false
-			} else {
-				// Continue adding to the multiline value
-				value += "\n" + line
+	}
+	if err := cmd.Start(); err != nil {
+		return commandExitCode(ctx, args, err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var kill <-chan time.Time
+	for {
+		select {
+		case sig := <-signals:
+			// nolint: errcheck
+			cmd.Process.Signal(sig)
+			if sig == syscall.SIGTERM && kill == nil {
+				kill = time.After(args.GracePeriod)
 			}
-			continue
-		}
-
-		// Parse line to get key, value, and multiline start
-		var val string
-		key, val, multiline, quoteType = parseLine(line)
-		if multiline {
-			value = val
-			continue
+		case <-kill:
+			slog.Warn("Command did not exit within the grace period, killing it", slog.String("gracePeriod", args.GracePeriod.String()))
+			// nolint: errcheck
+			cmd.Process.Kill()
+		case err := <-done:
+			return commandExitCode(ctx, args, err)
 		}
+	}
+}
 
-		// Expand variables for double-quoted values
-		if quoteType == '"' {
-			val = expandVariables(val, envVars)
-			val = strings.ReplaceAll(val, `\n`, "\n") // Handle \n as newlines
-		}
+// forwardedSignals are passed on to the command instead of terminating exportenv.
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
 
-		// Store key-value pair
-		envVars[key] = val
+// commandExitCode returns the exit code exportenv should exit with for the result of running the command.
+func commandExitCode(ctx context.Context, args Args, err error) int {
+	if err == nil {
+		return 0
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Error("Command timed out and was killed", slog.String("timeout", args.Timeout.String()))
+		return timeoutExitCode
 	}
-	return envVars, nil
-}
-
-// parseLine parses a line and returns the key, value, and whether it is a multiline start.
-func parseLine(line string) (string, string, bool, rune) {
-	keyValueLine := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
-	matches := keyValueLine.FindStringSubmatch(line)
-	if matches == nil {
-		return "", "", false, 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitCode(exitErr)
 	}
-
-	key, val := matches[1], matches[2]
-
-	// Remove inline comments if outside quotes
-	val = removeInlineComment(val)
-
-	// Check for quoted values (single or double)
-	if strings.HasPrefix(val, "\"") || strings.HasPrefix(val, "'") {
-		quoteType := rune(val[0])
-		val = strings.TrimPrefix(val, string(quoteType))
-
-		// Check if it's a single-line quoted value by verifying it ends with the same quote
-		if strings.HasSuffix(val, string(quoteType)) {
-			val = strings.TrimSuffix(val, string(quoteType))
-			return key, val, false, 0 // Single-line quoted value
-		}
-
-		// Start of a multiline quoted value
-		return key, val, true, quoteType
+	slog.Error("Error executing command", slog.Any("error", err))
+	if errors.Is(err, exec.ErrNotFound) {
+		return 127
 	}
-
-	// Unquoted single-line value
-	return key, val, false, 0
+	return 1
 }
 
-// isCommentOrEmpty checks if a line is a comment or empty.
-func isCommentOrEmpty(line string) bool {
-	return line == "" || strings.HasPrefix(line, "#")
+// newCommand returns the command given on the command line with the modified environment, running in
+// the directory given with --dir. The current environment without the --unset variables is inherited
+// unless --clean-env is set.
+func newCommand(ctx context.Context, args Args, envVars []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, args.Cmd[0], args.Cmd[1:]...)
+	cmd.Env = envVars
+	if !args.CleanEnv {
+		cmd.Env = append(withoutKeys(os.Environ(), args.Unset), envVars...)
+	}
+	cmd.Dir = args.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
 }
 
-// removeInlineComment removes inline comments if not inside quotes.
-func removeInlineComment(val string) string {
-	var result strings.Builder
-	inQuote := false
-	quoteChar := rune(0)
-
-	for _, char := range val {
-		if (char == '"' || char == '\'') && !inQuote {
-			// Starting a quoted section
-			inQuote = true
-			quoteChar = char
-		} else if char == quoteChar && inQuote {
-			// Ending a quoted section
-			inQuote = false
-		} else if char == '#' && !inQuote {
-			// Found a comment outside quotes; ignore the rest of the line
-			break
-		}
-		result.WriteRune(char)
+// commandDir returns the absolute path of the working directory given with --dir,
+// or an error if it isn't an existing directory.
+func commandDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
 	}
-
-	return strings.TrimSpace(result.String())
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	return dir, nil
 }
 
-// expandVariables expands ${VAR} syntax for double-quoted values.
-func expandVariables(val string, envVars map[string]string) string {
-	return os.Expand(val, func(varName string) string {
-		if v, exists := envVars[varName]; exists {
-			return v
-		}
-		return ""
-	})
+// exitCode returns the exit code of a terminated command. Commands killed by a signal
+// result in 128+signal, matching the behavior of POSIX shells.
+func exitCode(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
 }
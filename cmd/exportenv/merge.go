@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// MergeArgs are the arguments of the merge subcommand.
+type MergeArgs struct {
+	Override bool     `arg:"-o,--override" help:"Override variables from previous files if they already exist"`
+	Strict   bool     `arg:"--strict" help:"Reject shell-style export KEY=VALUE lines and abort on malformed lines in env files"`
+	Files    []string `arg:"positional,required" placeholder:"FILE" help:"Env files to merge, in order"`
+}
+
+// runMerge runs the merge subcommand, which loads env files like exportenv does and prints the result
+// as a single env file. Values are not expanded, so references are resolved when the merged file is loaded.
+func runMerge(argv []string) int {
+	var args MergeArgs
+	if _, code, ok := parseSubcommand("exportenv merge", &args, argv); !ok {
+		return code
+	}
+
+	entries, err := loadMerged(args)
+	if err != nil {
+		slog.Error("Error loading env files", slog.Any("error", err))
+		return 1
+	}
+
+	if err := printMerged(os.Stdout, entries, args.Files, time.Now()); err != nil {
+		slog.Error("Error writing output", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+// loadMerged loads the files to merge, sorted by key. References are kept as written in all values,
+// including double-quoted ones.
+func loadMerged(args MergeArgs) ([]envparse.Entry, error) {
+	entries, err := envparse.LoadEntries(args.Files,
+		envparse.WithOverride(args.Override),
+		envparse.WithStrict(args.Strict),
+		envparse.WithNoExpand(true),
+		envparse.WithWarningHandler(func(issue envparse.Issue) {
+			fmt.Fprintln(os.Stderr, issue)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	entrySorters["key"](entries)
+	return entries, nil
+}
+
+// printMerged prints the merged variables as an env file, preceded by a header naming the source files.
+// Values in backticks stay in backticks.
+func printMerged(w io.Writer, entries []envparse.Entry, files []string, now time.Time) error {
+	header := fmt.Sprintf("# Merged by exportenv from %s\n# Generated at %s\n",
+		strings.Join(files, ", "), now.UTC().Format(time.RFC3339))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	return envparse.WriteEntries(w, entries)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeEnvFile writes an env file with the given content to a temporary directory and returns its path.
+func writeEnvFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMergeKeepsReferences(t *testing.T) {
+	a := writeEnvFile(t, "a.env", "A=hello\nC=old\n")
+	b := writeEnvFile(t, "b.env", "B=\"${A} world\"\nC='${A:-x}'\nD=${A}\nE=`$5 ${A}`\n")
+
+	entries, err := loadMerged(MergeArgs{Files: []string{a, b}, Override: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printMerged(&buf, entries, []string{"a.env", "b.env"}, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# Merged by exportenv from a.env, b.env\n# Generated at 2024-01-02T03:04:05Z\n" +
+		"A=hello\nB=\"${A} world\"\nC=\"${A:-x}\"\nD=\"${A}\"\nE=`$5 ${A}`\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// parseEnviron parses a NUL-separated list of KEY=VALUE pairs as found in /proc/PID/environ.
+// Empty strings and strings without = are skipped.
+func parseEnviron(data []byte) map[string]string {
+	envVars := make(map[string]string)
+	for _, v := range bytes.Split(data, []byte{0}) {
+		if key, value, ok := strings.Cut(string(v), "="); ok && key != "" {
+			envVars[key] = value
+		}
+	}
+	return envVars
+}
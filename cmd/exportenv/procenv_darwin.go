@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// kernProcArgs2 is the KERN_PROCARGS2 sysctl, returning the arguments and environment of a process.
+const kernProcArgs2 = 49
+
+// processEnv returns the environment of the process with the given ID, as it was when the process
+// was started.
+func processEnv(pid int) (map[string]string, error) {
+	mib := []int32{1 /* CTL_KERN */, kernProcArgs2, int32(pid)}
+	size := uintptr(0)
+	if err := sysctl(mib, nil, &size); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if err := sysctl(mib, &buf[0], &size); err != nil {
+		return nil, err
+	}
+	buf = buf[:size]
+
+	// The buffer starts with argc, followed by the executable path, padding and the arguments,
+	// all separated by NULs, and ends with the environment
+	if len(buf) < 4 {
+		return nil, errors.New("unexpected process arguments")
+	}
+	argc := int(binary.LittleEndian.Uint32(buf))
+	buf = buf[4:]
+	i := bytes.IndexByte(buf, 0)
+	if i < 0 {
+		return nil, errors.New("unexpected process arguments")
+	}
+	buf = bytes.TrimLeft(buf[i:], "\x00")
+	for ; argc > 0; argc-- {
+		if i = bytes.IndexByte(buf, 0); i < 0 {
+			return nil, errors.New("unexpected process arguments")
+		}
+		buf = buf[i+1:]
+	}
+	// The environment ends with an empty string
+	if i = bytes.Index(buf, []byte{0, 0}); i >= 0 {
+		buf = buf[:i]
+	}
+	return parseEnviron(buf), nil
+}
+
+// sysctl calls the sysctl system call, which the syscall package doesn't expose for binary results.
+func sysctl(mib []int32, old *byte, oldLen *uintptr) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS___SYSCTL, uintptr(unsafe.Pointer(&mib[0])), uintptr(len(mib)),
+		uintptr(unsafe.Pointer(old)), uintptr(unsafe.Pointer(oldLen)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
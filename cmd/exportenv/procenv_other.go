@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// processEnv isn't supported on this platform.
+func processEnv(int) (map[string]string, error) {
+	return nil, errors.New("reading the environment of a process is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// profileName matches the names accepted by --profile, which become part of a file name.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// profileFiles returns the env files loaded for a profile in the order .env, .env.<profile>, .env.local,
+// like Next.js and Create React App. Files that don't exist are left out.
+func profileFiles(profile string) []string {
+	return existingFiles([]string{
+		envparse.DefaultFile,
+		envparse.DefaultFile + "." + profile,
+		envparse.DefaultFile + ".local",
+	})
+}
+
+// cascadeFiles returns the env files loaded with --cascade in the order .env, .env.local,
+// .env.<hostname>, .env.<hostname>.local, like Vue CLI. Files that don't exist are left out.
+func cascadeFiles() ([]string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return existingFiles([]string{
+		envparse.DefaultFile,
+		envparse.DefaultFile + ".local",
+		envparse.DefaultFile + "." + hostname,
+		envparse.DefaultFile + "." + hostname + ".local",
+	}), nil
+}
+
+// existingFiles returns the files that exist, keeping their order.
+func existingFiles(candidates []string) []string {
+	files := make([]string, 0, len(candidates))
+	for _, file := range candidates {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
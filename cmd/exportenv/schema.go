@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// envSchema is the subset of JSON Schema supported by --schema: an object whose properties describe
+// the variables. Other keywords are ignored.
+type envSchema struct {
+	Properties           map[string]*schemaProperty `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties *bool                      `json:"additionalProperties"`
+}
+
+// schemaProperty describes a single variable. Values are strings, they are converted to the type
+// before the other keywords are checked.
+type schemaProperty struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Enum        []any    `json:"enum"`
+	Pattern     string   `json:"pattern"`
+	MinLength   *int     `json:"minLength"`
+	MaxLength   *int     `json:"maxLength"`
+	Minimum     *float64 `json:"minimum"`
+	Maximum     *float64 `json:"maximum"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes are the types a property may have.
+var schemaTypes = []string{"", "string", "integer", "number", "boolean"}
+
+// loadSchema reads the JSON Schema at path.
+func loadSchema(path string) (*envSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema envSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for key, p := range schema.Properties {
+		if p == nil {
+			p = &schemaProperty{}
+			schema.Properties[key] = p
+		}
+		if !slices.Contains(schemaTypes, p.Type) {
+			return nil, fmt.Errorf("%s: %s: unsupported type %q", path, key, p.Type)
+		}
+		if p.Pattern != "" {
+			if p.pattern, err = regexp.Compile(p.Pattern); err != nil {
+				return nil, fmt.Errorf("%s: %s: invalid pattern: %w", path, key, err)
+			}
+		}
+	}
+	return &schema, nil
+}
+
+// validate checks the variables against the schema and returns an error listing every invalid variable
+// and every required variable that is missing.
+func (s *envSchema) validate(entries []envparse.Entry) error {
+	var errs []error
+	defined := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		defined[e.Key] = true
+		p, ok := s.Properties[e.Key]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				errs = append(errs, fmt.Errorf("%s: not defined in the schema", e.Key))
+			}
+			continue
+		}
+		if err := p.validate(e.Value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+		}
+	}
+	for _, key := range s.Required {
+		if !defined[key] {
+			errs = append(errs, fmt.Errorf("%s: required but not defined", key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks a single value against the property.
+func (p *schemaProperty) validate(value string) error {
+	number, err := p.convert(value)
+	if err != nil {
+		return err
+	}
+	if len(p.Enum) > 0 && !slices.ContainsFunc(p.Enum, func(allowed any) bool {
+		if n, ok := allowed.(float64); ok && (p.Type == "integer" || p.Type == "number") {
+			return n == number
+		}
+		return fmt.Sprint(allowed) == value
+	}) {
+		return fmt.Errorf("%q is not one of the allowed values", value)
+	}
+	if p.pattern != nil && !p.pattern.MatchString(value) {
+		return fmt.Errorf("%q does not match the pattern %s", value, p.Pattern)
+	}
+	length := len([]rune(value))
+	if p.MinLength != nil && length < *p.MinLength {
+		return fmt.Errorf("value is shorter than %d characters", *p.MinLength)
+	}
+	if p.MaxLength != nil && length > *p.MaxLength {
+		return fmt.Errorf("value is longer than %d characters", *p.MaxLength)
+	}
+	if p.Type == "integer" || p.Type == "number" {
+		if p.Minimum != nil && number < *p.Minimum {
+			return fmt.Errorf("%s is less than the minimum %v", value, *p.Minimum)
+		}
+		if p.Maximum != nil && number > *p.Maximum {
+			return fmt.Errorf("%s is greater than the maximum %v", value, *p.Maximum)
+		}
+	}
+	return nil
+}
+
+// convert checks that value has the type of the property and returns its number for the types integer
+// and number.
+func (p *schemaProperty) convert(value string) (float64, error) {
+	switch p.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", value)
+		}
+		return float64(n), nil
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", value)
+		}
+		return n, nil
+	case "boolean":
+		if _, ok := booleans[strings.ToLower(value)]; !ok {
+			return 0, fmt.Errorf("%q is not a boolean", value)
+		}
+	}
+	return 0, nil
+}
+
+// generatedSchema is the JSON Schema written by --generate-schema.
+type generatedSchema struct {
+	Schema     string                       `json:"$schema"`
+	Type       string                       `json:"type"`
+	Properties map[string]generatedProperty `json:"properties"`
+	Required   []string                     `json:"required"`
+}
+
+// generatedProperty is a property of the generated schema.
+type generatedProperty struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+}
+
+// generateSchema returns a draft-07 JSON Schema in which every variable is a required string with
+// its value as default. The values of the masked keys are replaced with *** like in the output.
+func generateSchema(entries []envparse.Entry, masked []string) ([]byte, error) {
+	schema := generatedSchema{
+		Schema:     "http://json-schema.org/draft-07/schema#",
+		Type:       "object",
+		Properties: make(map[string]generatedProperty, len(entries)),
+		Required:   make([]string, 0, len(entries)),
+	}
+	for _, e := range entries {
+		value := e.Value
+		if slices.Contains(masked, e.Key) {
+			value = maskedValue
+		}
+		schema.Properties[e.Key] = generatedProperty{Type: "string", Description: "TODO: describe " + e.Key, Default: value}
+		schema.Required = append(schema.Required, e.Key)
+	}
+	slices.Sort(schema.Required)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
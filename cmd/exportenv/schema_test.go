@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	entries := []envparse.Entry{
+		{Key: "PORT", Value: "8080"},
+		{Key: "API_TOKEN", Value: "s3cr3t"},
+		{Key: "URL", Value: "https://example.com/?a=1&b=<2>"},
+	}
+	schema, err := generateSchema(entries, []string{"API_TOKEN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "API_TOKEN": {
+      "type": "string",
+      "description": "TODO: describe API_TOKEN",
+      "default": "***"
+    },
+    "PORT": {
+      "type": "string",
+      "description": "TODO: describe PORT",
+      "default": "8080"
+    },
+    "URL": {
+      "type": "string",
+      "description": "TODO: describe URL",
+      "default": "https://example.com/?a=1&b=<2>"
+    }
+  },
+  "required": [
+    "API_TOKEN",
+    "PORT",
+    "URL"
+  ]
+}
+`
+	if string(schema) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", schema, want)
+	}
+
+	// The generated schema is accepted by --schema and the variables it was generated from are valid
+	path := filepath.Join(t.TempDir(), "env.schema.json")
+	if err := os.WriteFile(path, schema, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(loaded.Required, []string{"API_TOKEN", "PORT", "URL"}) {
+		t.Errorf("required = %q", loaded.Required)
+	}
+	if err := loaded.validate(entries); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSchemaRequiredAfterTransformations(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "app.schema.json")
+	content := `{"properties": {"PORT": {"type": "integer"}}, "required": ["PORT", "HOST"]}`
+	if err := os.WriteFile(schemaPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := loadSchema(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envFile := writeEnvFile(t, ".env", "APP_PORT=8080\nAPP_HOST=localhost\nOTHER=x\n")
+
+	args := Args{EnvFiles: []string{envFile}, Schema: schemaPath, schema: schema, Prefix: "APP_", StripPrefix: "APP_"}
+	entries, err := loadEntries(args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := envparse.ToMap(entries); got["PORT"] != "8080" || got["HOST"] != "localhost" {
+		t.Errorf("got %v", got)
+	}
+
+	// Without --strip-prefix, the required keys are missing
+	args.StripPrefix = ""
+	_, err = loadEntries(args, nil)
+	if err == nil || !strings.Contains(err.Error(), "PORT: required but not defined") {
+		t.Errorf("got error %v, want PORT to be missing", err)
+	}
+}
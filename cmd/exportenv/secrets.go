@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// secretKeyWords are parts of keys that suggest a secret value.
+var secretKeyWords = []string{"SECRET", "PASSWORD", "TOKEN", "KEY", "CREDENTIAL"}
+
+// secretValues match values that look like secrets, with the reason reported for them. Values matching
+// a pattern with mixed set must contain digits and letters of both cases, as long words and paths
+// would match otherwise.
+var secretValues = []struct {
+	pattern *regexp.Regexp
+	mixed   bool
+	reason  string
+}{
+	{regexp.MustCompile(`^(AKIA|ASIA)[A-Z0-9]{16}$`), false, "value looks like an AWS access key ID"},
+	{regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`), false, "value looks like a JWT"},
+	{regexp.MustCompile(`^[A-Za-z0-9+/]{21,}={0,2}$`), true, "value looks like a Base64-encoded blob"},
+}
+
+// secretReason returns why a variable likely holds a secret, judging by its key and value,
+// or an empty string if it doesn't look like one.
+func secretReason(key, value string) string {
+	upper := strings.ToUpper(key)
+	for _, word := range secretKeyWords {
+		if strings.Contains(upper, word) {
+			return "key contains " + word
+		}
+	}
+	for _, v := range secretValues {
+		if v.pattern.MatchString(value) && (!v.mixed || isMixed(value)) {
+			return v.reason
+		}
+	}
+	return ""
+}
+
+// looksSecret reports whether a variable likely holds a secret, see secretReason.
+func looksSecret(key, value string) bool {
+	return secretReason(key, value) != ""
+}
+
+// isMixed reports whether s contains digits as well as upper and lower case letters.
+func isMixed(s string) bool {
+	var digit, upper, lower bool
+	for _, r := range s {
+		digit = digit || unicode.IsDigit(r)
+		upper = upper || unicode.IsUpper(r)
+		lower = lower || unicode.IsLower(r)
+	}
+	return digit && upper && lower
+}
+
+// warnSecrets logs a warning for every variable that looks like a secret. It is a safety net against
+// committing secrets in env files, not a replacement for a secrets scanner.
+func warnSecrets(entries []envparse.Entry) {
+	for _, e := range entries {
+		if reason := secretReason(e.Key, e.Value); reason != "" {
+			slog.Warn("Variable looks like a secret",
+				slog.String("key", e.Key),
+				slog.String("reason", reason),
+				slog.String("file", e.File),
+				slog.Int("line", e.Line),
+			)
+		}
+	}
+}
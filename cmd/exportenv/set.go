@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// SetArgs are the arguments of the set subcommand.
+type SetArgs struct {
+	File  string `arg:"--file" default:".env" help:"Env file to update, created if it does not exist"`
+	Key   string `arg:"positional,required" help:"Name of the variable to set"`
+	Value string `arg:"positional,required" help:"Value of the variable"`
+}
+
+// runSet runs the set subcommand, which adds or updates a variable in an env file. The file is
+// written atomically and keeps its permissions.
+func runSet(argv []string) int {
+	var args SetArgs
+	if _, code, ok := parseSubcommand("exportenv set", &args, argv); !ok {
+		return code
+	}
+
+	// New files are only readable by the current user, as they are likely to hold secrets
+	perm := os.FileMode(0o600)
+	content, err := os.ReadFile(args.File)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		slog.Error("Error reading env file", slog.Any("error", err))
+		return 1
+	default:
+		info, err := os.Stat(args.File)
+		if err != nil {
+			slog.Error("Error reading env file", slog.Any("error", err))
+			return 1
+		}
+		perm = info.Mode().Perm()
+	}
+
+	updated, err := envparse.SetValue(content, args.Key, args.Value)
+	if err != nil {
+		slog.Error("Error setting variable", slog.Any("error", err))
+		return 1
+	}
+
+	err = writeFileAtomic(args.File, perm, func(w io.Writer) error {
+		_, err := w.Write(updated)
+		return err
+	})
+	if err != nil {
+		slog.Error("Error writing env file", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
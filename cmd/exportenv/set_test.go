@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if code, _ := runSubcommand(t, runSet, "--file", path, "HOST", "localhost"); code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("new file has mode %o, want 600", perm)
+	}
+
+	// Existing files keep their permissions and other lines
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	for _, argv := range [][]string{{"--file", path, "HOST", "example.com"}, {"--file", path, "URL", "$HOST/x"}} {
+		if code, _ := runSubcommand(t, runSet, argv...); code != 0 {
+			t.Fatalf("exit code = %d, want 0", code)
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "HOST=example.com\nURL=`$HOST/x`\n"; string(content) != want {
+		t.Errorf("got %q, want %q", content, want)
+	}
+	if info, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o640 {
+		t.Errorf("updated file has mode %o, want 640", perm)
+	}
+
+	if code, _ := runSubcommand(t, runSet, "--file", path, "1HOST", "x"); code != 1 {
+		t.Errorf("invalid key: exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// entrySorters maps the values accepted by --sort-by to a function ordering the entries in place.
+var entrySorters = map[string]func(entries []envparse.Entry){
+	"key": func(entries []envparse.Entry) {
+		slices.SortFunc(entries, func(a, b envparse.Entry) int {
+			return cmp.Compare(a.Key, b.Key)
+		})
+	},
+	"value": func(entries []envparse.Entry) {
+		slices.SortFunc(entries, func(a, b envparse.Entry) int {
+			return cmp.Or(cmp.Compare(a.Value, b.Value), cmp.Compare(a.Key, b.Key))
+		})
+	},
+	"length": func(entries []envparse.Entry) {
+		slices.SortFunc(entries, func(a, b envparse.Entry) int {
+			return cmp.Or(cmp.Compare(len(a.Key), len(b.Key)), cmp.Compare(a.Key, b.Key))
+		})
+	},
+	// Entries are already in the order they were first defined
+	"none": func([]envparse.Entry) {},
+	"file": sortByFile,
+}
+
+// sortByFile groups entries by the file they were taken from, in the order the files first provide
+// a variable. Within a file, entries keep the order of their lines. Variables that don't come from
+// a file are put last.
+func sortByFile(entries []envparse.Entry) {
+	rank := fileRanks(entries)
+	slices.SortStableFunc(entries, func(a, b envparse.Entry) int {
+		return cmp.Or(cmp.Compare(rank[a.File], rank[b.File]), cmp.Compare(a.Line, b.Line))
+	})
+}
+
+// groupByFile groups entries by the file they were taken from, ordering the files by rank, but keeps
+// their order within a file.
+func groupByFile(entries []envparse.Entry, rank map[string]int) {
+	slices.SortStableFunc(entries, func(a, b envparse.Entry) int {
+		return cmp.Compare(rank[a.File], rank[b.File])
+	})
+}
+
+// fileRanks numbers the files in the order they first provide a variable. Variables that don't come
+// from a file rank last.
+func fileRanks(entries []envparse.Entry) map[string]int {
+	rank := make(map[string]int)
+	for _, e := range entries {
+		if _, exists := rank[e.File]; !exists && e.File != "" {
+			rank[e.File] = len(rank)
+		}
+	}
+	rank[""] = len(rank)
+	return rank
+}
+
+// sortEntries returns the entries as KEY=VALUE pairs in the order selected with --sort-by,
+// grouped by file with --group-by-file.
+func sortEntries(entries []envparse.Entry, args Args) []string {
+	sorted := slices.Clone(entries)
+	entrySorters[args.SortBy](sorted)
+	if args.GroupByFile {
+		// Files are ranked by the original order, which the sorting above lost
+		groupByFile(sorted, fileRanks(entries))
+	}
+
+	envVars := make([]string, len(sorted))
+	for i, e := range sorted {
+		envVars[i] = e.Key + "=" + e.Value
+	}
+	return envVars
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// sortTestEntries are in definition order: b.env is loaded after a.env, but provides the first variable.
+var sortTestEntries = []envparse.Entry{
+	{Key: "ZED", Value: "1", File: "b.env", Line: 2},
+	{Key: "A", Value: "3", File: "a.env", Line: 5},
+	{Key: "MIDDLE", Value: "2", File: "b.env", Line: 1},
+	{Key: "CLI", Value: "0"},
+	{Key: "BB", Value: "1", File: "a.env", Line: 1},
+}
+
+func TestSortEntries(t *testing.T) {
+	tests := []struct {
+		args Args
+		want []string
+	}{
+		{args: Args{SortBy: "key"}, want: []string{"A=3", "BB=1", "CLI=0", "MIDDLE=2", "ZED=1"}},
+		{args: Args{SortBy: "value"}, want: []string{"CLI=0", "BB=1", "ZED=1", "MIDDLE=2", "A=3"}},
+		{args: Args{SortBy: "length"}, want: []string{"A=3", "BB=1", "CLI=0", "ZED=1", "MIDDLE=2"}},
+		{args: Args{SortBy: "none"}, want: []string{"ZED=1", "A=3", "MIDDLE=2", "CLI=0", "BB=1"}},
+		{args: Args{SortBy: "file"}, want: []string{"MIDDLE=2", "ZED=1", "BB=1", "A=3", "CLI=0"}},
+		{args: Args{SortBy: "key", GroupByFile: true}, want: []string{"MIDDLE=2", "ZED=1", "A=3", "BB=1", "CLI=0"}},
+		{args: Args{SortBy: "none", GroupByFile: true}, want: []string{"ZED=1", "MIDDLE=2", "A=3", "BB=1", "CLI=0"}},
+	}
+	for _, tt := range tests {
+		name := tt.args.SortBy
+		if tt.args.GroupByFile {
+			name += " grouped by file"
+		}
+		t.Run(name, func(t *testing.T) {
+			entries := slices.Clone(sortTestEntries)
+			if got := sortEntries(entries, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(entries, sortTestEntries) {
+				t.Error("sortEntries modified the entries")
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/alexflint/go-arg"
+)
+
+// subcommands maps the subcommand names to the functions running them. They are dispatched by hand,
+// go-arg doesn't support subcommands together with the positional command. Each function receives
+// the arguments following the subcommand name and returns the exit code.
+var subcommands = map[string]func(argv []string) int{
+	"convert": runConvert,
+	"diff":    runDiff,
+	"fmt":     runFmt,
+	"get":     runGet,
+	"lint":    runLint,
+	"merge":   runMerge,
+	"set":     runSet,
+}
+
+// lookupSubcommand returns the subcommand named by the first of the arguments and the arguments
+// following it. Only the first argument can name a subcommand, so a command with the name of a
+// subcommand still runs if it follows -- or a flag, as in exportenv -- diff a.txt b.txt.
+func lookupSubcommand(argv []string) (func(argv []string) int, []string, bool) {
+	if len(argv) == 0 {
+		return nil, nil, false
+	}
+	run, ok := subcommands[argv[0]]
+	return run, argv[1:], ok
+}
+
+// usageErrorExitCode is returned by subcommands for invalid arguments.
+const usageErrorExitCode = 2
+
+// parseSubcommand parses argv into dest, the arguments of a subcommand. If the subcommand shouldn't run,
+// it returns false with the exit code: 0 after printing the help, 2 after a usage error.
+func parseSubcommand(program string, dest any, argv []string) (*arg.Parser, int, bool) {
+	parser, err := arg.NewParser(arg.Config{Program: program}, dest)
+	if err != nil {
+		slog.Error("Error creating argument parser", slog.Any("error", err))
+		return nil, usageErrorExitCode, false
+	}
+	if err := parser.Parse(argv); err != nil {
+		if errors.Is(err, arg.ErrHelp) {
+			parser.WriteHelp(os.Stdout)
+			return nil, 0, false
+		}
+		return nil, usageError(parser, err.Error()), false
+	}
+	return parser, 0, true
+}
+
+// usageError prints the usage of a subcommand followed by msg and returns the exit code for usage errors.
+func usageError(parser *arg.Parser, msg string) int {
+	parser.WriteUsage(os.Stderr)
+	fmt.Fprintln(os.Stderr, "error:", msg)
+	return usageErrorExitCode
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestLookupSubcommand(t *testing.T) {
+	tests := []struct {
+		name string
+		argv []string
+		want []string
+		ok   bool
+	}{
+		{name: "no arguments", argv: nil},
+		{name: "subcommand", argv: []string{"diff", "a.env", "b.env"}, want: []string{"a.env", "b.env"}, ok: true},
+		{name: "subcommand without arguments", argv: []string{"lint"}, want: []string{}, ok: true},
+		{name: "command after separator", argv: []string{"--", "diff", "a.txt", "b.txt"}},
+		{name: "command after flag", argv: []string{"--env-file", "a.env", "diff", "a.txt", "b.txt"}},
+		{name: "other command", argv: []string{"echo", "diff"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, argv, ok := lookupSubcommand(tt.argv)
+			if ok != tt.ok || (run != nil) != tt.ok {
+				t.Fatalf("lookupSubcommand(%q) found a subcommand: %t, want %t", tt.argv, ok, tt.ok)
+			}
+			if ok && !slices.Equal(argv, tt.want) {
+				t.Errorf("arguments = %q, want %q", argv, tt.want)
+			}
+		})
+	}
+}
+
+// runSubcommand runs a subcommand with the arguments and returns its exit code and its output on
+// stdout. Tests using it must not run in parallel, as it replaces os.Stdout.
+func runSubcommand(t *testing.T, run func(argv []string) int, argv ...string) (int, string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+	code := run(argv)
+	w.Close()
+	return code, <-output
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// templateMissingModes are the accepted values of --template-missing.
+var templateMissingModes = []string{"error", "ignore"}
+
+// renderTemplate renders the Go template file at path to w. Variables are referenced as {{.KEY}} or
+// {{env "KEY"}}. Missing variables are an error, unless missing is "ignore", which renders them empty.
+func renderTemplate(w io.Writer, path string, envVars map[string]string, missing string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	funcs := template.FuncMap{
+		"env": func(key string) (string, error) {
+			value, ok := envVars[key]
+			if !ok && missing != "ignore" {
+				return "", fmt.Errorf("variable %s is not defined", key)
+			}
+			return value, nil
+		},
+	}
+	missingKey := "missingkey=error"
+	if missing == "ignore" {
+		missingKey = "missingkey=zero"
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(funcs).Option(missingKey).Parse(string(content))
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, envVars)
+}
+
+// writeTemplate renders --template to --output-file or stdout and returns the exit code exportenv
+// should exit with. Nothing is written if rendering fails.
+func writeTemplate(envVars map[string]string, args Args) int {
+	var buf bytes.Buffer
+	if err := renderTemplate(&buf, args.Template, envVars, args.TemplateMissing); err != nil {
+		slog.Error("Error rendering template", slog.Any("error", err))
+		return 1
+	}
+	return writeOutput(buf.Bytes(), args)
+}
+
+// writeOutput writes content to --output-file or stdout and returns the exit code exportenv should
+// exit with.
+func writeOutput(content []byte, args Args) int {
+	var err error
+	if args.OutputFile != "" {
+		// The output may contain secrets, so it is only readable by the current user
+		err = writeFileAtomic(args.OutputFile, 0o600, func(w io.Writer) error {
+			_, err := w.Write(content)
+			return err
+		})
+	} else {
+		_, err = os.Stdout.Write(content)
+	}
+	if err != nil {
+		slog.Error("Error writing output", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
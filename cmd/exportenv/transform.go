@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// filterPrefix removes all variables whose key doesn't start with prefix.
+func filterPrefix(entries []envparse.Entry, prefix string) []envparse.Entry {
+	return slices.DeleteFunc(entries, func(e envparse.Entry) bool {
+		return !strings.HasPrefix(e.Key, prefix)
+	})
+}
+
+// stripPrefix removes prefix from the keys that start with it. Other keys are left unchanged,
+// unless a stripped key replaces them; the replaced keys are returned as well.
+func stripPrefix(entries []envparse.Entry, prefix string) ([]envparse.Entry, []string) {
+	// Rename first, a renamed key might match a key that is only removed afterwards
+	renamed := make([]bool, len(entries))
+	strippedKeys := make(map[string]bool)
+	for i, e := range entries {
+		if newKey, ok := strings.CutPrefix(e.Key, prefix); ok && newKey != "" {
+			entries[i].Key = newKey
+			renamed[i] = true
+			strippedKeys[newKey] = true
+		}
+	}
+
+	result := entries[:0]
+	var replaced []string
+	for i, e := range entries {
+		if renamed[i] || !strippedKeys[e.Key] {
+			result = append(result, e)
+			continue
+		}
+		replaced = append(replaced, e.Key)
+	}
+	return result, replaced
+}
+
+// addPrefix prepends prefix to all keys.
+func addPrefix(entries []envparse.Entry, prefix string) []envparse.Entry {
+	for i := range entries {
+		entries[i].Key = prefix + entries[i].Key
+	}
+	return entries
+}
+
+// namespacePrefix returns the key prefix of the namespace given with --namespace and --strip-namespace.
+func namespacePrefix(namespace string) string {
+	return strings.ToUpper(namespace) + "_"
+}
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// rename changes the key of a variable from From to To.
+type rename struct {
+	From, To string
+}
+
+// parseRenames parses --rename flags of the form KEY=NEWKEY.
+func parseRenames(specs []string) ([]rename, error) {
+	renames := make([]rename, 0, len(specs))
+	for _, spec := range specs {
+		from, to, ok := strings.Cut(spec, "=")
+		if !ok || !variableName.MatchString(from) || !variableName.MatchString(to) {
+			return nil, fmt.Errorf("invalid rename %q, expected KEY=NEWKEY", spec)
+		}
+		renames = append(renames, rename{From: from, To: to})
+	}
+	return renames, nil
+}
+
+// renameKeys applies the renames in order. If the new key is already defined, the renamed variable
+// replaces it with override and is dropped otherwise.
+func renameKeys(entries []envparse.Entry, renames []rename, override bool) []envparse.Entry {
+	for _, r := range renames {
+		i := slices.IndexFunc(entries, func(e envparse.Entry) bool { return e.Key == r.From })
+		if i < 0 || r.From == r.To {
+			continue
+		}
+		existing := slices.IndexFunc(entries, func(e envparse.Entry) bool { return e.Key == r.To })
+		entries[i].Key = r.To
+		switch {
+		case existing < 0:
+		case override:
+			entries = slices.Delete(entries, existing, existing+1)
+		default:
+			entries = slices.Delete(entries, i, i+1)
+		}
+	}
+	return entries
+}
+
+// convertKeys applies convert to all keys. Keys that become equal are an error.
+func convertKeys(entries []envparse.Entry, convert func(string) string) ([]envparse.Entry, error) {
+	original := make(map[string][]string)
+	for i, e := range entries {
+		entries[i].Key = convert(e.Key)
+		original[entries[i].Key] = append(original[entries[i].Key], e.Key)
+	}
+
+	var collisions []string
+	for key, keys := range original {
+		if len(keys) > 1 {
+			collisions = append(collisions, fmt.Sprintf("%s all become %s", strings.Join(keys, ", "), key))
+		}
+	}
+	if len(collisions) > 0 {
+		slices.Sort(collisions)
+		return nil, fmt.Errorf("keys collide after case conversion: %s", strings.Join(collisions, "; "))
+	}
+	return entries, nil
+}
+
+// maskedValue replaces the values of variables selected with --mask-value in the output.
+const maskedValue = "***"
+
+// maskValues returns a copy of the KEY=VALUE pairs with the values of the given keys masked.
+func maskValues(envVars []string, keys []string) []string {
+	masked := slices.Clone(envVars)
+	for i, v := range masked {
+		if key, _, _ := strings.Cut(v, "="); slices.Contains(keys, key) {
+			masked[i] = key + "=" + maskedValue
+		}
+	}
+	return masked
+}
+
+// decodeBase64 replaces Base64-encoded values with their decoded form. With all, every value that
+// decodes to valid UTF-8 is replaced and other values are left unchanged. The values of keys are
+// always decoded, a value that isn't Base64 of UTF-8 text is an error.
+func decodeBase64(entries []envparse.Entry, all bool, keys []string) ([]envparse.Entry, error) {
+	for i, e := range entries {
+		required := slices.Contains(keys, e.Key)
+		if !all && !required || e.Value == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(e.Value)
+		if err == nil && !utf8.Valid(decoded) {
+			err = errors.New("decoded value is not valid UTF-8")
+		}
+		switch {
+		case err == nil:
+			entries[i].Value = string(decoded)
+		case required:
+			return nil, fmt.Errorf("decoding Base64 value of %s: %w", e.Key, err)
+		}
+	}
+	return entries, nil
+}
+
+// urlDecode replaces percent-encoded sequences in the values of keys, or in all values with all.
+// Unlike in query strings, + is kept, as it is common in passwords. Values with malformed sequences
+// are left unchanged with a warning.
+func urlDecode(entries []envparse.Entry, all bool, keys []string) []envparse.Entry {
+	for i, e := range entries {
+		if !all && !slices.Contains(keys, e.Key) {
+			continue
+		}
+		decoded, err := url.PathUnescape(e.Value)
+		if err != nil {
+			slog.Warn("Value is not URL-encoded, leaving it unchanged", slog.String("key", e.Key), slog.Any("error", err))
+			continue
+		}
+		entries[i].Value = decoded
+	}
+	return entries
+}
+
+// booleans maps the lower case values recognized as booleans by --normalize-booleans to their truth value.
+var booleans = map[string]bool{
+	"true": true, "yes": true, "on": true, "1": true,
+	"false": false, "no": false, "off": false, "0": false,
+}
+
+// boolFormat holds the values normalized booleans are written as.
+type boolFormat struct {
+	True, False string
+}
+
+// parseBoolFormat parses --bool-format, a pair of a true and a false value in either order,
+// such as true/false or 0/1.
+func parseBoolFormat(spec string) (boolFormat, error) {
+	a, b, ok := strings.Cut(spec, "/")
+	aValue, aKnown := booleans[strings.ToLower(a)]
+	bValue, bKnown := booleans[strings.ToLower(b)]
+	if !ok || !aKnown || !bKnown || aValue == bValue {
+		return boolFormat{}, fmt.Errorf("invalid bool format %q, expected a true and a false value such as true/false", spec)
+	}
+	if aValue {
+		return boolFormat{True: a, False: b}, nil
+	}
+	return boolFormat{True: b, False: a}, nil
+}
+
+// normalizeBooleans rewrites the values recognized as booleans, regardless of case, in the given format.
+// Other values are left unchanged.
+func normalizeBooleans(entries []envparse.Entry, format boolFormat) []envparse.Entry {
+	for i, e := range entries {
+		value, ok := booleans[strings.ToLower(e.Value)]
+		switch {
+		case !ok:
+		case value:
+			entries[i].Value = format.True
+		default:
+			entries[i].Value = format.False
+		}
+	}
+	return entries
+}
+
+// withoutEntries removes the variables with the given keys.
+func withoutEntries(entries []envparse.Entry, keys []string) []envparse.Entry {
+	return slices.DeleteFunc(entries, func(e envparse.Entry) bool {
+		return slices.Contains(keys, e.Key)
+	})
+}
+
+// withoutDefined removes the variables that are set to a non-empty value in the current environment,
+// so the env files only provide defaults for it.
+func withoutDefined(entries []envparse.Entry) []envparse.Entry {
+	return slices.DeleteFunc(entries, func(e envparse.Entry) bool {
+		return os.Getenv(e.Key) != ""
+	})
+}
+
+// withoutUnchanged removes the variables that are already set to the same value in the current environment.
+func withoutUnchanged(entries []envparse.Entry) []envparse.Entry {
+	return slices.DeleteFunc(entries, func(e envparse.Entry) bool {
+		value, ok := os.LookupEnv(e.Key)
+		return ok && value == e.Value
+	})
+}
+
+// excludeKeys removes the variables whose key matches one of the glob patterns.
+// The patterns must be valid, see validPatterns.
+func excludeKeys(entries []envparse.Entry, patterns []string) []envparse.Entry {
+	return slices.DeleteFunc(entries, func(e envparse.Entry) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, e.Key)
+			return matched
+		})
+	})
+}
+
+// validPatterns returns an error for the first glob pattern with invalid syntax.
+func validPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cbrgm/exportenv/pkg/envparse"
+)
+
+// watch runs the command and restarts it with freshly loaded variables whenever one of the env files
+// changes. Changes are detected by polling the files every --watch-poll-interval. It only returns if
+// exportenv receives a signal, which is forwarded to the command, with the exit code exportenv should
+// exit with.
+func watch(args Args, opts []envparse.Option) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+
+	ticker := time.NewTicker(args.WatchPollInterval)
+	defer ticker.Stop()
+
+	var (
+		cmd  *exec.Cmd
+		done chan error
+	)
+	state := watchState(args)
+	start := func() {
+		cmd, done = nil, nil
+		entries, err := loadEntries(args, opts)
+		if err != nil {
+			slog.Error("Error loading env files, waiting for changes", slog.Any("error", err))
+			return
+		}
+
+		c := newCommand(context.Background(), args, sortEntries(entries, args))
+		if args.AuditLog != "" {
+			if err := errors.Join(auditLoaded(args.AuditLog, entries, args.CleanEnv), auditExec(args.AuditLog, c.Env)); err != nil {
+				slog.Error("Error writing audit log, waiting for changes", slog.Any("error", err))
+				return
+			}
+		}
+		if err := c.Start(); err != nil {
+			slog.Error("Error executing command, waiting for changes", slog.Any("error", err))
+			return
+		}
+		cmd, done = c, make(chan error, 1)
+		go func() { done <- c.Wait() }()
+	}
+
+	start()
+	for {
+		select {
+		case sig := <-signals:
+			if cmd != nil {
+				stopCommand(cmd, done, sig, args.GracePeriod)
+			}
+			if s, ok := sig.(syscall.Signal); ok {
+				return 128 + int(s)
+			}
+			return 1
+		case err := <-done:
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitCode(exitErr)
+			}
+			slog.Info("Command exited, waiting for changes", slog.Int("exitCode", code))
+			cmd, done = nil, nil
+		case <-ticker.C:
+			next := watchState(args)
+			if next == state {
+				continue
+			}
+			state = next
+			slog.Info("Env files changed, restarting command")
+			if cmd != nil {
+				stopCommand(cmd, done, syscall.SIGTERM, args.GracePeriod)
+			}
+			start()
+		}
+	}
+}
+
+// stopCommand sends sig to the command and waits for it to exit. The command is killed if it
+// is still running after the grace period, or if it can't receive signals, as on Windows.
+func stopCommand(cmd *exec.Cmd, done <-chan error, sig os.Signal, gracePeriod time.Duration) {
+	if err := cmd.Process.Signal(sig); err != nil {
+		// nolint: errcheck
+		cmd.Process.Kill()
+		<-done
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(gracePeriod):
+		slog.Warn("Command did not exit within the grace period, killing it", slog.String("gracePeriod", gracePeriod.String()))
+		// nolint: errcheck
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+// watchState returns a description of the local files loaded with the given arguments that changes
+// whenever one of them is modified, created or removed. Env files fetched from URLs are not watched.
+func watchState(args Args) string {
+	var files []string
+	if len(args.EnvFiles) == 0 && len(args.EnvDirs) == 0 {
+		files = append(files, envparse.DefaultFile)
+	}
+	for _, file := range args.EnvFiles {
+		if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+			continue
+		}
+		// Patterns are matched again on every poll, so new files are picked up
+		if strings.ContainsAny(file, "*?") {
+			matches, _ := filepath.Glob(file)
+			files = append(files, matches...)
+			continue
+		}
+		files = append(files, file)
+	}
+	for _, dir := range args.EnvDirs {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if filepath.Ext(entry.Name()) == args.EnvDirExt {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	if args.ImportFromShell != "" {
+		files = append(files, args.ImportFromShell)
+	}
+
+	var state strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintf(&state, "%s: missing\n", file)
+			continue
+		}
+		fmt.Fprintf(&state, "%s: %d %d\n", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return state.String()
+}
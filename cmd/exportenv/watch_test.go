@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchState(t *testing.T) {
+	dir := t.TempDir()
+	env := writeEnvFile(t, ".env", "A=a\n")
+	args := Args{EnvFiles: []string{env, filepath.Join(dir, "*.env"), "https://example.com/app.env"}}
+
+	state := watchState(args)
+	if next := watchState(args); next != state {
+		t.Fatalf("state changed without changes to the files:\n%s\n%s", state, next)
+	}
+
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{name: "modified", change: func() error { return os.WriteFile(env, []byte("A=ab\n"), 0o600) }},
+		{name: "matching file created", change: func() error { return os.WriteFile(filepath.Join(dir, "b.env"), nil, 0o600) }},
+		{name: "removed", change: func() error { return os.Remove(env) }},
+	}
+	for _, c := range changes {
+		if err := c.change(); err != nil {
+			t.Fatal(err)
+		}
+		next := watchState(args)
+		if next == state {
+			t.Errorf("%s: state did not change", c.name)
+		}
+		state = next
+	}
+}
+
+func TestStopCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   syscall.Signal
+	}{
+		{name: "exits on signal", script: "sleep 10", want: syscall.SIGTERM},
+		{name: "killed after grace period", script: `trap "" TERM; sleep 10`, want: syscall.SIGKILL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", tt.script)
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan error, 1)
+			go func() { done <- cmd.Wait() }()
+			// Give the shell time to set up its trap
+			time.Sleep(100 * time.Millisecond)
+
+			stopCommand(cmd, done, syscall.SIGTERM, 200*time.Millisecond)
+			status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+			if !ok || !status.Signaled() || status.Signal() != tt.want {
+				t.Errorf("command ended with %v, want %v", cmd.ProcessState, tt.want)
+			}
+		})
+	}
+}
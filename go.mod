@@ -2,6 +2,10 @@ module github.com/cbrgm/exportenv
 
 go 1.23.2
 
-require github.com/alexflint/go-arg v1.5.1
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/alexflint/go-arg v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require github.com/alexflint/go-scalar v1.2.0 // indirect
@@ -0,0 +1,99 @@
+package envparse
+
+import "sort"
+
+// Entry is a loaded variable together with the location it was defined at.
+type Entry struct {
+	Key   string
+	Value string
+	// File and Line locate the definition. File is empty for variables that don't come from an env file,
+	// such as those added with WithSource and WithVars or assigned by ${VAR:=default}.
+	File string
+	Line int
+	// Literal is set for values in backticks or triple backticks, which are not expanded.
+	Literal bool
+}
+
+// ToMap returns the entries as a map from key to value.
+func ToMap(entries []Entry) map[string]string {
+	envVars := make(map[string]string, len(entries))
+	for _, e := range entries {
+		envVars[e.Key] = e.Value
+	}
+	return envVars
+}
+
+// entryList holds entries in the order their keys were first defined.
+type entryList struct {
+	entries []Entry
+	// index holds the position of each key in entries
+	index map[string]int
+}
+
+func newEntryList() *entryList {
+	return &entryList{index: make(map[string]int)}
+}
+
+// has reports whether key is defined.
+func (l *entryList) has(key string) bool {
+	_, exists := l.index[key]
+	return exists
+}
+
+// get returns the entry of key, which must be defined.
+func (l *entryList) get(key string) Entry {
+	return l.entries[l.index[key]]
+}
+
+// set adds e, or replaces the entry with the same key while keeping its position.
+func (l *entryList) set(e Entry) {
+	if i, exists := l.index[e.Key]; exists {
+		l.entries[i] = e
+		return
+	}
+	l.index[e.Key] = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+// delete removes the entry of key, if it is defined.
+func (l *entryList) delete(key string) {
+	i, exists := l.index[key]
+	if !exists {
+		return
+	}
+	l.entries = append(l.entries[:i], l.entries[i+1:]...)
+	delete(l.index, key)
+	for j := i; j < len(l.entries); j++ {
+		l.index[l.entries[j].Key] = j
+	}
+}
+
+// literals returns the keys whose values are not expanded.
+func (l *entryList) literals() map[string]bool {
+	literals := make(map[string]bool)
+	for _, e := range l.entries {
+		if e.Literal {
+			literals[e.Key] = true
+		}
+	}
+	return literals
+}
+
+// setValues applies values to the entries. Keys not defined yet are appended in sorted order.
+func (l *entryList) setValues(values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if l.has(k) {
+			e := l.get(k)
+			e.Value = values[k]
+			l.set(e)
+			continue
+		}
+		l.set(Entry{Key: k, Value: values[k]})
+	}
+}
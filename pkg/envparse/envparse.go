@@ -0,0 +1,575 @@
+// Package envparse parses .env files and loads them into environment variables.
+package envparse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultFile is loaded if no env files are given.
+	DefaultFile = ".env"
+	// Stdin is the file name that reads an env file from standard input.
+	Stdin = "-"
+)
+
+// Option configures Load.
+type Option func(*options)
+
+type options struct {
+	override      bool
+	noExpand      bool
+	ignoreMissing bool
+	strict        bool
+	// noBackslashContinue disables joining lines that end with a backslash
+	noBackslashContinue bool
+	// noDirectives disables "unset KEY" and "include FILE" lines
+	noDirectives bool
+	// noIncludes skips include directives, to edit a file without reading the files it includes
+	noIncludes bool
+	// including holds the chain of env files whose include directives are being followed
+	including []string
+	// format overrides the format detected by the file extension
+	format Format
+	stdin  io.Reader
+	// httpClient, token and retries are used to fetch env files from URLs
+	httpClient *http.Client
+	token      string
+	retries    int
+	sources    []map[string]string
+	// dirs holds directories whose files with the extension dirExt are loaded before the files
+	dirs   []string
+	dirExt string
+	vars   map[string]string
+	// warn receives warnings about the loaded files, such as ignored duplicates if warnDuplicates is set
+	warn           func(Issue)
+	warnDuplicates bool
+	// protected variables keep their first value, like variables annotated with override-priority=never,
+	// and aren't overridden by sources and vars either
+	protected         []string
+	warnProtected     bool
+	strictPermissions bool
+	// limit is the number of variables loaded from each file, if not zero
+	limit int
+	// skipInvalid drops the errors about malformed lines instead of passing them to warn
+	skipInvalid bool
+	// interpolation is the syntax of references, the default is ${VAR}
+	interpolation Interpolation
+	// vaultPassword decrypts the YAML values tagged !vault, if set
+	vaultPassword string
+}
+
+// WithOverride makes succeeding files overwrite variables from previous files.
+func WithOverride(override bool) Option {
+	return func(o *options) {
+		o.override = override
+	}
+}
+
+// WithNoExpand disables variable expansion, references are kept as written in all values.
+func WithNoExpand(noExpand bool) Option {
+	return func(o *options) {
+		o.noExpand = noExpand
+	}
+}
+
+// WithIgnoreMissing skips env files that don't exist instead of failing.
+func WithIgnoreMissing(ignoreMissing bool) Option {
+	return func(o *options) {
+		o.ignoreMissing = ignoreMissing
+	}
+}
+
+// WithStrict disables the tolerance for shell-style "export KEY=VALUE" lines and makes malformed
+// lines an error when loading, instead of reporting them to the warning handler and skipping them.
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+// WithNoBackslashContinue keeps trailing backslashes instead of joining the line with the next one.
+func WithNoBackslashContinue(noBackslashContinue bool) Option {
+	return func(o *options) {
+		o.noBackslashContinue = noBackslashContinue
+	}
+}
+
+// WithNoDirectives disables "unset KEY" directives, which remove variables defined by previous files,
+// and "include FILE" directives, which load another env file.
+func WithNoDirectives(noDirectives bool) Option {
+	return func(o *options) {
+		o.noDirectives = noDirectives
+	}
+}
+
+// WithFormat sets the format of all env files. By default, files ending in .json are read as JSON,
+// files ending in .yaml or .yml as YAML, files ending in .toml as TOML and all other files, including
+// stdin, as env files.
+func WithFormat(format Format) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithHTTPClient sets the client used to fetch env files from http:// and https:// URLs.
+// It defaults to a client with DefaultHTTPTimeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithBearerToken sets a token sent as bearer authorization when fetching env files from URLs.
+func WithBearerToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithRetries sets how often fetching an env file from a URL is retried after a transient error.
+func WithRetries(retries int) Option {
+	return func(o *options) {
+		o.retries = retries
+	}
+}
+
+// WithDir loads every file with the extension ext (e.g. ".env") from dir in lexicographic order,
+// before the files passed to Load. An empty ext selects files without an extension. Subdirectories
+// are skipped. The option can be given multiple times to load several directories in order.
+func WithDir(dir, ext string) Option {
+	return func(o *options) {
+		o.dirs = append(o.dirs, dir)
+		o.dirExt = ext
+	}
+}
+
+// WithStdin sets the reader used for the "-" file name. It defaults to os.Stdin.
+func WithStdin(r io.Reader) Option {
+	return func(o *options) {
+		o.stdin = r
+	}
+}
+
+// WithSource adds variables that are merged after the env files, following the same override rules as a file.
+func WithSource(vars map[string]string) Option {
+	return func(o *options) {
+		o.sources = append(o.sources, vars)
+	}
+}
+
+// WithVars sets variables that take precedence over all files and sources.
+func WithVars(vars map[string]string) Option {
+	return func(o *options) {
+		o.vars = vars
+	}
+}
+
+// WithWarningHandler sets a function called for suspicious but valid findings while loading env files,
+// such as files that other users can read.
+func WithWarningHandler(warn func(Issue)) Option {
+	return func(o *options) {
+		o.warn = warn
+	}
+}
+
+// WithDuplicateWarnings reports every variable of a file that is ignored because a previous file
+// already defined it and may not be overridden to the warning handler.
+func WithDuplicateWarnings(warnDuplicates bool) Option {
+	return func(o *options) {
+		o.warnDuplicates = warnDuplicates
+	}
+}
+
+// WithProtected makes the variables keep the value they are first defined with. They are neither
+// overridden by succeeding files, regardless of WithOverride and annotations, nor by sources and vars.
+func WithProtected(keys []string) Option {
+	return func(o *options) {
+		o.protected = keys
+	}
+}
+
+// WithProtectedWarnings reports every value of a protected variable that is ignored to the warning handler.
+func WithProtectedWarnings(warnProtected bool) Option {
+	return func(o *options) {
+		o.warnProtected = warnProtected
+	}
+}
+
+// WithVaultPassword decrypts the values of YAML files tagged !vault, encrypted with Ansible Vault, with
+// password. Without a password, they are loaded as written.
+func WithVaultPassword(password string) Option {
+	return func(o *options) {
+		o.vaultPassword = password
+	}
+}
+
+// WithStrictPermissions makes env files that other users can read an error instead of a warning.
+func WithStrictPermissions(strictPermissions bool) Option {
+	return func(o *options) {
+		o.strictPermissions = strictPermissions
+	}
+}
+
+// WithInterpolation sets the syntax of references to other variables in values. InterpolationNone
+// disables expansion like WithNoExpand.
+func WithInterpolation(interpolation Interpolation) Option {
+	return func(o *options) {
+		o.interpolation = interpolation
+	}
+}
+
+// WithSkipInvalid skips malformed lines silently instead of reporting them to the warning handler.
+func WithSkipInvalid(skipInvalid bool) Option {
+	return func(o *options) {
+		o.skipInvalid = skipInvalid
+	}
+}
+
+// WithLimit only loads the first n variables of each env file, in the order they are defined.
+// Zero loads all variables.
+func WithLimit(n int) Option {
+	return func(o *options) {
+		o.limit = n
+	}
+}
+
+// warning passes issue to the warning handler, if one is set.
+func (o *options) warning(issue Issue) {
+	if o.warn != nil {
+		o.warn(issue)
+	}
+}
+
+// newOptions applies opts to the default options.
+func newOptions(opts []Option) *options {
+	o := &options{stdin: os.Stdin, httpClient: &http.Client{Timeout: DefaultHTTPTimeout}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Load loads variables from multiple env files in order, using .env as a default if no files are provided.
+// Variables from the options are merged afterwards and all values are expanded unless disabled.
+func Load(files []string, opts ...Option) (map[string]string, error) {
+	entries, err := LoadEntries(files, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ToMap(entries), nil
+}
+
+// Environ loads variables like Load and returns them as KEY=VALUE pairs sorted by key, the format of
+// os.Environ and exec.Cmd.Env. The current environment is not included; append the result to
+// os.Environ to let the variables from the files take precedence.
+func Environ(files []string, opts ...Option) ([]string, error) {
+	envVars, err := Load(files, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Sort(envVars), nil
+}
+
+// LoadEntries loads variables like Load, but returns them in the order they were first defined,
+// together with the file and line each value was taken from.
+func LoadEntries(files []string, opts ...Option) ([]Entry, error) {
+	o := newOptions(opts)
+	list, err := loadEnvFiles(files, o)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, source := range o.sources {
+		mergeSource(list, source, o.override, o)
+	}
+	mergeSource(list, o.vars, true, o)
+
+	if !o.noExpand {
+		envVars := ToMap(list.entries)
+		if err := expandWith(envVars, o.interpolation, list.literals()); err != nil {
+			// Point to the definition of the variable if it comes from a file
+			var expandErr *ExpandError
+			if errors.As(err, &expandErr) && list.has(expandErr.Key) && list.get(expandErr.Key).File != "" {
+				e := list.get(expandErr.Key)
+				return nil, lineError(e.File, e.Line, err)
+			}
+			return nil, err
+		}
+		list.setValues(envVars)
+	}
+	return list.entries, nil
+}
+
+// loadEnvFiles loads variables from multiple env files in order, using .env as a default if no files are provided.
+// If override is true, succeeding files will overwrite variables from previous files. A variable's
+// override-priority annotation takes precedence over override.
+func loadEnvFiles(files []string, o *options) (*entryList, error) {
+	parsedFiles, err := parseEnvFiles(files, o)
+	if err != nil {
+		return nil, err
+	}
+
+	list := newEntryList()
+	// immune holds variables annotated with override-priority=never and protected variables
+	immune := make(map[string]bool)
+	for _, parsed := range parsedFiles {
+		// Problems in a file don't stop loading unless in strict mode, but are reported with their line
+		for _, issue := range parsed.issues {
+			switch {
+			case issue.Severity != SeverityError:
+				o.warning(issue)
+			case o.strict:
+				return nil, lineError(issue.File, issue.Line, errors.New(issue.Message))
+			case !o.skipInvalid:
+				o.warning(issue)
+			}
+		}
+		for k := range parsed.unsets {
+			if !immune[k] {
+				list.delete(k)
+			}
+		}
+		keys := parsed.keys()
+		if o.limit > 0 && len(keys) > o.limit {
+			keys = keys[:o.limit]
+		}
+		for _, k := range keys {
+			// Set variable only if it doesn't exist, override is true or the variable always overrides
+			if !list.has(k) || (!immune[k] && (o.override || parsed.priorities[k] == priorityAlways)) {
+				file, line := parsed.location(k)
+				list.set(Entry{Key: k, Value: parsed.vars[k], File: file, Line: line, Literal: isLiteralQuote(parsed.quotes[k])})
+				immune[k] = parsed.priorities[k] == priorityNever || slices.Contains(o.protected, k)
+				continue
+			}
+			if o.warnProtected && slices.Contains(o.protected, k) {
+				file, line := parsed.location(k)
+				o.warning(Issue{
+					File:     file,
+					Line:     line,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s is protected, it is already defined in %s", k, list.get(k).File),
+				})
+				continue
+			}
+			if o.warnDuplicates {
+				file, line := parsed.location(k)
+				o.warning(Issue{
+					File:     file,
+					Line:     line,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s is ignored, it is already defined in %s", k, list.get(k).File),
+				})
+			}
+		}
+	}
+	return list, nil
+}
+
+// parseEnvFiles parses env files in order, using .env as a default if no files are provided.
+// The file name "-" reads from stdin, http:// and https:// URLs are fetched.
+func parseEnvFiles(files []string, o *options) ([]*parsedFile, error) {
+	// Use .env as default if no files are specified, it is optional
+	ignoreMissing := o.ignoreMissing
+	if len(files) == 0 && len(o.dirs) == 0 {
+		files = []string{DefaultFile}
+		ignoreMissing = true
+	}
+
+	files, err := expandGlobs(files, ignoreMissing)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirFiles []string
+	for _, dir := range o.dirs {
+		matches, err := listDir(dir, o.dirExt, ignoreMissing)
+		if err != nil {
+			return nil, err
+		}
+		dirFiles = append(dirFiles, matches...)
+	}
+	files = append(dirFiles, files...)
+
+	// stdin is consumed by the first read, a second read would silently yield nothing
+	if i := slices.Index(files, Stdin); i >= 0 && slices.Contains(files[i+1:], Stdin) {
+		return nil, errors.New("stdin (-) can only be used once as env file")
+	}
+
+	// Files are parsed in parallel, but the results are used in the order of the files
+	results := make([]*parsedFile, len(files))
+	errs := make([]error, len(files))
+	warnings := make([][]Issue, len(files))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Following includes and reporting warnings modify the options, so each file gets a copy.
+			// Warnings are collected to report them in order.
+			fileOpts := *o
+			fileOpts.including = nil
+			fileOpts.warn = func(issue Issue) { warnings[i] = append(warnings[i], issue) }
+			results[i], errs[i] = parseFile(file, &fileOpts)
+		}()
+	}
+	wg.Wait()
+
+	parsedFiles := make([]*parsedFile, 0, len(files))
+	for i, parsed := range results {
+		for _, issue := range warnings[i] {
+			o.warning(issue)
+		}
+		if err := errs[i]; err != nil {
+			if ignoreMissing && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		parsedFiles = append(parsedFiles, parsed)
+	}
+	return parsedFiles, nil
+}
+
+// parseFile parses a single env file, which may be stdin or a URL.
+func parseFile(file string, o *options) (*parsedFile, error) {
+	switch {
+	case file == Stdin:
+		return parseFormat(o.stdin, "stdin", fileFormat("", o), o)
+	case isURL(file):
+		return parseURL(file, o)
+	default:
+		return parseEnvFile(file, o)
+	}
+}
+
+// expandGlobs replaces every file pattern containing * or ? with the lexicographically sorted files it matches.
+// Patterns without matches are an error unless ignoreMissing is true.
+func expandGlobs(files []string, ignoreMissing bool) ([]string, error) {
+	expanded := make([]string, 0, len(files))
+	for _, file := range files {
+		if isURL(file) || !strings.ContainsAny(file, "*?") {
+			expanded = append(expanded, file)
+			continue
+		}
+
+		matches, err := filepath.Glob(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(matches) == 0 && !ignoreMissing {
+			return nil, fmt.Errorf("%s: pattern matches no files", file)
+		}
+		sort.Strings(matches)
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// listDir returns the files in dir with the extension ext in lexicographic order, skipping subdirectories.
+// If ignoreMissing is true, a missing directory and unreadable files are skipped instead of failing.
+func listDir(dir, ext string, ignoreMissing bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if ignoreMissing && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// os.ReadDir returns the entries sorted by file name
+	var files []string
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ext {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Stat follows symlinks, so links to directories are skipped as well
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			continue
+		}
+		if err == nil && ignoreMissing {
+			err = checkReadable(path)
+		}
+		if err != nil {
+			if ignoreMissing {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// checkReadable returns an error if the file at path can't be opened for reading.
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// mergeSource merges the variables of a single source into list, in sorted order.
+// If override is true, existing variables are replaced unless they are protected.
+func mergeSource(list *entryList, source map[string]string, override bool, o *options) {
+	keys := make([]string, 0, len(source))
+	for k := range source {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// Set variable only if it doesn't exist or override is true
+		switch {
+		case !list.has(k):
+		case !override:
+			continue
+		case slices.Contains(o.protected, k):
+			if o.warnProtected {
+				e := list.get(k)
+				o.warning(Issue{
+					File:     e.File,
+					Line:     e.Line,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("%s is protected, a value given later is ignored", k),
+				})
+			}
+			continue
+		}
+		list.set(Entry{Key: k, Value: source[k]})
+	}
+}
+
+// Merge merges vars into envVars, overwriting existing variables.
+func Merge(envVars, vars map[string]string) {
+	for k, v := range vars {
+		envVars[k] = v
+	}
+}
+
+// Sort returns the environment variables as KEY=VALUE pairs sorted by key.
+func Sort(envVars map[string]string) []string {
+	keys := make([]string, 0, len(envVars))
+	for k := range envVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sortedEnv := make([]string, len(envVars))
+	for i, k := range keys {
+		sortedEnv[i] = k + "=" + envVars[k]
+	}
+	return sortedEnv
+}
@@ -0,0 +1,57 @@
+package envparse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLoadNoExpand(t *testing.T) {
+	files := writeFiles(t, "A=hello\n", "B=\"${A} world\"\nC=${A}\nD='${A}'\nE=\"${UNSET:?is required}\"\n")
+	envVars, err := Load(files, WithNoExpand(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "hello", "B": "${A} world", "C": "${A}", "D": "${A}", "E": "${UNSET:?is required}"}
+	for k, v := range want {
+		if envVars[k] != v {
+			t.Errorf("%s = %q, want %q", k, envVars[k], v)
+		}
+	}
+}
+
+// BenchmarkLoad compares parsing N env files in parallel, as Load does, with parsing them one after
+// another. Merging the files afterwards is sequential either way and not measured.
+func BenchmarkLoad(b *testing.B) {
+	var content strings.Builder
+	content.WriteString("BASE=base\n")
+	for i := range 500 {
+		fmt.Fprintf(&content, "# Setting %d\nKEY_%d=\"value ${BASE} %d\"\n", i, i, i)
+	}
+
+	for _, n := range []int{1, 5, 20} {
+		contents := make([]string, n)
+		for i := range contents {
+			contents[i] = content.String()
+		}
+		files := writeFiles(b, contents...)
+
+		b.Run(fmt.Sprintf("parallel/files=%d", n), func(b *testing.B) {
+			for range b.N {
+				if _, err := parseEnvFiles(files, newOptions(nil)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("sequential/files=%d", n), func(b *testing.B) {
+			for range b.N {
+				o := newOptions(nil)
+				for _, file := range files {
+					if _, err := parseFile(file, o); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package envparse
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Interpolation is a syntax for references to variables in values.
+type Interpolation string
+
+const (
+	// InterpolationDollarBrace is the shell syntax, ${VAR} and $VAR. It is the default.
+	InterpolationDollarBrace Interpolation = "dollar-brace"
+	// InterpolationDollarParen is the Makefile syntax, $(VAR).
+	InterpolationDollarParen Interpolation = "dollar-paren"
+	// InterpolationPercent is the Windows syntax, %VAR%.
+	InterpolationPercent Interpolation = "percent"
+	// InterpolationNone disables expansion.
+	InterpolationNone Interpolation = "none"
+)
+
+// Interpolations lists the supported interpolation syntaxes.
+var Interpolations = []Interpolation{InterpolationDollarBrace, InterpolationDollarParen, InterpolationPercent, InterpolationNone}
+
+// Expand performs variable expansion (e.g., ${VAR} syntax) in the values of envVars. Referenced variables
+// are expanded first, so the result doesn't depend on the order of the variables. Circular references
+// are reported as an error.
+func Expand(envVars map[string]string) error {
+	return expandWith(envVars, InterpolationDollarBrace, nil)
+}
+
+// expandWith performs variable expansion like Expand, recognizing references in the given syntax. The
+// values of literal keys are not expanded, but can be referenced.
+func expandWith(envVars map[string]string, syntax Interpolation, literal map[string]bool) error {
+	keys := make([]string, 0, len(envVars))
+	pending := make(map[string]bool, len(envVars))
+	for k := range envVars {
+		if literal[k] {
+			continue
+		}
+		keys = append(keys, k)
+		pending[k] = true
+	}
+	sort.Strings(keys)
+
+	e := &expander{envVars: envVars, pending: pending, syntax: syntax}
+	for _, key := range keys {
+		if !e.pending[key] {
+			continue
+		}
+		if _, err := e.resolve(key); err != nil {
+			return &ExpandError{Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// ExpandError is returned by Expand if the value of a variable can't be expanded.
+type ExpandError struct {
+	Key string
+	Err error
+}
+
+func (e *ExpandError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *ExpandError) Unwrap() error {
+	return e.Err
+}
+
+// expander expands variable references using the values of envVars.
+type expander struct {
+	envVars map[string]string
+	// pending holds the variables whose values are not expanded yet
+	pending map[string]bool
+	// chain holds the variables currently being expanded, to detect circular references
+	chain []string
+	// syntax is the syntax of references
+	syntax Interpolation
+}
+
+// resolve expands the value of the variable name and stores the result in envVars.
+func (e *expander) resolve(name string) (string, error) {
+	for i, n := range e.chain {
+		if n == name {
+			cycle := append(append([]string{}, e.chain[i:]...), name)
+			return "", fmt.Errorf("circular reference detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	e.chain = append(e.chain, name)
+	expanded, err := e.expand(e.envVars[name])
+	e.chain = e.chain[:len(e.chain)-1]
+	if err != nil {
+		return "", err
+	}
+
+	e.envVars[name] = expanded
+	delete(e.pending, name)
+	return expanded, nil
+}
+
+// lookup returns the expanded value of the variable name.
+func (e *expander) lookup(name string) (string, error) {
+	if e.pending[name] {
+		return e.resolve(name)
+	}
+	return e.envVars[name], nil
+}
+
+// expand expands all variable references in val.
+func (e *expander) expand(val string) (string, error) {
+	var expandErr error
+	expanded := expandSyntax(val, e.syntax, func(expr string) string {
+		if expandErr != nil {
+			return ""
+		}
+		v, err := e.expandParameter(expr)
+		if err != nil {
+			expandErr = err
+		}
+		return v
+	})
+	return expanded, expandErr
+}
+
+// expandParameter resolves a single parameter expression, supporting the POSIX operators:
+//   - ${VAR:-default} returns default if VAR is unset or empty.
+//   - ${VAR:=default} also assigns default to VAR.
+//   - ${VAR:?message} fails with message if VAR is unset or empty.
+//   - ${VAR:+alternative} returns alternative if VAR is set and not empty, nothing otherwise.
+//   - ${VAR#pattern} and ${VAR##pattern} remove the shortest and longest prefix matching pattern.
+//   - ${VAR%pattern} and ${VAR%%pattern} remove the shortest and longest suffix matching pattern.
+func (e *expander) expandParameter(expr string) (string, error) {
+	name, operator, word := splitParameter(expr)
+	value, err := e.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if isTrimOperator(operator) {
+		pattern, err := e.expand(word)
+		if err != nil {
+			return "", err
+		}
+		return trimPattern(value, operator, pattern), nil
+	}
+	if operator == ":+" {
+		if value == "" {
+			return "", nil
+		}
+		return e.expand(word)
+	}
+	if value != "" || operator == "" {
+		return value, nil
+	}
+
+	switch operator {
+	case ":-", ":=":
+		expanded, err := e.expand(word)
+		if err != nil {
+			return "", err
+		}
+		if operator == ":=" {
+			e.envVars[name] = expanded
+			delete(e.pending, name)
+		}
+		return expanded, nil
+	default: // ":?"
+		message, err := e.expand(word)
+		if err != nil {
+			return "", err
+		}
+		if message == "" {
+			message = "parameter null or not set"
+		}
+		return "", &RequiredError{Name: name, Message: message}
+	}
+}
+
+// RequiredError is returned when a ${VAR:?message} reference is expanded while VAR is unset or empty.
+type RequiredError struct {
+	Name    string
+	Message string
+}
+
+func (e *RequiredError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Message)
+}
+
+// parameterOperators are the operators supported in parameter expressions. Longer operators come
+// first, so ## is not taken for #.
+var parameterOperators = []string{":-", ":=", ":?", ":+", "##", "#", "%%", "%"}
+
+// splitParameter splits a parameter expression such as VAR:-default into its name, operator and word.
+func splitParameter(expr string) (string, string, string) {
+	end := strings.IndexFunc(expr, notNameChar)
+	if end <= 0 {
+		return expr, "", ""
+	}
+	for _, operator := range parameterOperators {
+		if word, ok := strings.CutPrefix(expr[end:], operator); ok {
+			return expr[:end], operator, word
+		}
+	}
+	return expr, "", ""
+}
+
+// notNameChar reports whether r can't be part of a variable name.
+func notNameChar(r rune) bool {
+	return r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9')
+}
+
+// isTrimOperator reports whether operator removes a prefix or suffix.
+func isTrimOperator(operator string) bool {
+	return operator != "" && strings.ContainsRune("#%", rune(operator[0]))
+}
+
+// trimPattern removes the shortest (# and %) or longest (## and %%) prefix (# and ##) or suffix
+// (% and %%) of value matching the glob pattern, in which * matches any string and ? any character.
+func trimPattern(value, operator, pattern string) string {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(re)
+	match := regexp.MustCompile("^(?s:" + re + ")$").MatchString
+
+	longest := len(operator) == 2
+	for n := range len(value) + 1 {
+		if longest {
+			n = len(value) - n
+		}
+		if operator[0] == '#' && match(value[:n]) {
+			return value[n:]
+		}
+		if operator[0] == '%' && match(value[len(value)-n:]) {
+			return value[:len(value)-n]
+		}
+	}
+	return value
+}
+
+// expandSyntax replaces the references of the given syntax in val with the result of mapping, which
+// receives the parameter expression of each reference, e.g. VAR:-default.
+func expandSyntax(val string, syntax Interpolation, mapping func(string) string) string {
+	switch syntax {
+	case InterpolationDollarParen:
+		return expandDelimited(val, "$(", ")", mapping)
+	case InterpolationPercent:
+		return expandDelimited(val, "%", "%", mapping)
+	case InterpolationNone:
+		return val
+	default:
+		return expandDollarBrace(val, mapping)
+	}
+}
+
+// expandDollarBrace replaces ${VAR} and $VAR references in val with the result of mapping, like
+// os.Expand, except that braces nested in a reference belong to its expression, as in ${A:-${B}}.
+func expandDollarBrace(val string, mapping func(string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(val, '$')
+		if start < 0 || start == len(val)-1 {
+			break
+		}
+		b.WriteString(val[:start])
+		expr, width := dollarReference(val[start+1:])
+		switch {
+		case expr != "":
+			b.WriteString(mapping(expr))
+		case width == 0:
+			// A $ that isn't followed by a name is kept
+			b.WriteByte('$')
+		}
+		val = val[start+1+width:]
+	}
+	b.WriteString(val)
+	return b.String()
+}
+
+// dollarReference returns the expression of the reference at the start of s, which follows a $, and
+// the number of bytes it takes. Malformed references, such as ${} or an unterminated ${, have no
+// expression and are dropped like os.Expand does.
+func dollarReference(s string) (string, int) {
+	if s[0] == '{' {
+		depth := 0
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == '{':
+				depth++
+			case s[i] == '}' && depth > 0:
+				depth--
+			case s[i] == '}':
+				return s[1:i], i + 1
+			}
+		}
+		return "", 1
+	}
+	// Special parameters such as $1 and $@ are a single character
+	if strings.IndexByte("*#$@!?-0123456789", s[0]) >= 0 {
+		return s[:1], 1
+	}
+	end := strings.IndexFunc(s, notNameChar)
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], end
+}
+
+// expandDelimited replaces references enclosed in open and close in val with the result of mapping.
+// Enclosed text that doesn't start with a variable name, such as "% of %" in "50% of %TOTAL%", is
+// kept as it is.
+func expandDelimited(val, open, close string, mapping func(string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(val, open)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(val[start+len(open):], close)
+		if end < 0 {
+			break
+		}
+		expr := val[start+len(open) : start+len(open)+end]
+		b.WriteString(val[:start])
+		if name, _, _ := splitParameter(expr); !variableName.MatchString(name) {
+			// Keep the opening delimiter, the closing one may open the next reference
+			b.WriteString(open)
+			val = val[start+len(open):]
+			continue
+		}
+		b.WriteString(mapping(expr))
+		val = val[start+len(open)+end+len(close):]
+	}
+	b.WriteString(val)
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package envparse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "braces", value: "${B}/x", want: "b/x"},
+		{name: "plain", value: "$B/x", want: "b/x"},
+		{name: "unset", value: "[${UNSET}]", want: "[]"},
+		{name: "default", value: "${UNSET:-d}", want: "d"},
+		{name: "default not used", value: "${B:-d}", want: "b"},
+		{name: "nested default", value: "${UNSET:-${B}}", want: "b"},
+		{name: "nested default with suffix", value: "${UNSET:-${B}}/x", want: "b/x"},
+		{name: "doubly nested default", value: "${UNSET:-${UNSET2:-${B}}}", want: "b"},
+		{name: "default with braces", value: "${UNSET:-{d}}", want: "{d}"},
+		{name: "empty uses default", value: "${EMPTY:-d}", want: "d"},
+		{name: "alternative", value: "${B:+alt}", want: "alt"},
+		{name: "alternative unset", value: "${UNSET:+alt}", want: ""},
+		{name: "nested alternative", value: "${B:+${PATHS}}", want: "a/b/c"},
+		{name: "shortest prefix", value: "${PATHS#*/}", want: "b/c"},
+		{name: "longest prefix", value: "${PATHS##*/}", want: "c"},
+		{name: "shortest suffix", value: "${PATHS%/*}", want: "a/b"},
+		{name: "longest suffix", value: "${PATHS%%/*}", want: "a"},
+		{name: "trailing dollar", value: "cost$", want: "cost$"},
+		{name: "dollar without name", value: "a $ b", want: "a $ b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVars := map[string]string{"B": "b", "EMPTY": "", "PATHS": "a/b/c", "V": tt.value}
+			if err := Expand(envVars); err != nil {
+				t.Fatal(err)
+			}
+			if envVars["V"] != tt.want {
+				t.Errorf("%s = %q, want %q", tt.value, envVars["V"], tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandAssignDefault(t *testing.T) {
+	envVars := map[string]string{"A": "${UNSET:=${B}}", "B": "b"}
+	if err := Expand(envVars); err != nil {
+		t.Fatal(err)
+	}
+	if envVars["A"] != "b" || envVars["UNSET"] != "b" {
+		t.Errorf("A = %q, UNSET = %q, want both %q", envVars["A"], envVars["UNSET"], "b")
+	}
+}
+
+func TestExpandRequired(t *testing.T) {
+	envVars := map[string]string{"A": "${UNSET:?UNSET is required}"}
+	var required *RequiredError
+	if err := Expand(envVars); !errors.As(err, &required) {
+		t.Fatalf("Expand() = %v, want a RequiredError", err)
+	}
+	if required.Name != "UNSET" || required.Message != "UNSET is required" {
+		t.Errorf("RequiredError = %+v", required)
+	}
+}
+
+func TestExpandCircular(t *testing.T) {
+	envVars := map[string]string{"A": "${B}", "B": "${A}"}
+	if err := Expand(envVars); err == nil {
+		t.Error("Expand() succeeded, want an error for the circular reference")
+	}
+}
@@ -0,0 +1,211 @@
+package envparse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a file format that variables can be decoded from and encoded to.
+type Format string
+
+const (
+	// FormatDotenv is the KEY=VALUE format of .env files.
+	FormatDotenv Format = "dotenv"
+	// FormatJSON is a JSON object mapping keys to values.
+	FormatJSON Format = "json"
+	// FormatYAML is a YAML mapping of keys to values.
+	FormatYAML Format = "yaml"
+	// FormatTOML is a TOML document with a key/value pair per variable, either at the root or in an
+	// [env] table.
+	FormatTOML Format = "toml"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatDotenv, FormatJSON, FormatYAML, FormatTOML}
+
+// DetectFormat returns the format of a file by its extension. Files with other extensions are
+// assumed to be env files.
+func DetectFormat(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatDotenv
+	}
+}
+
+// Decode reads variables in the given format from r. Structured formats must hold a mapping; numbers
+// and booleans are converted to strings, null values to empty strings. Nested JSON and TOML objects are
+// flattened by joining the keys with an underscore, YAML only supports flat mappings. A TOML document
+// holding only an [env] table is read from that table. YAML values tagged !vault are decrypted with
+// WithVaultPassword. Values are not expanded.
+func Decode(r io.Reader, format Format, opts ...Option) (map[string]string, error) {
+	switch format {
+	case FormatDotenv:
+		return Parse(r, opts...)
+	case FormatJSON:
+		return decodeJSON(r)
+	case FormatYAML:
+		envVars, _, err := decodeYAML(r, newOptions(opts).vaultPassword)
+		return envVars, err
+	case FormatTOML:
+		return decodeTOML(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Encode writes the variables to w in the given format, sorted by key.
+func Encode(w io.Writer, envVars map[string]string, format Format) error {
+	switch format {
+	case FormatDotenv:
+		return Write(w, envVars)
+	case FormatJSON:
+		// encoding/json sorts map keys, which keeps the output stable
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(envVars)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(envVars); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case FormatTOML:
+		return toml.NewEncoder(w).Encode(envVars)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// decodeJSON reads a flat JSON object.
+func decodeJSON(r io.Reader) (map[string]string, error) {
+	var values map[string]any
+	decoder := json.NewDecoder(r)
+	// Keep numbers as written instead of converting them to float64
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	return scalarValues(values)
+}
+
+// decodeYAML reads a flat YAML mapping. Scalars are taken as written, e.g. 1.0 stays 1.0, and block
+// scalars keep their line breaks. The line of each key is returned as well. Values tagged !vault are
+// decrypted with vaultPassword, or taken as written if it is empty.
+func decodeYAML(r io.Reader, vaultPassword string) (map[string]string, map[string]int, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return map[string]string{}, map[string]int{}, nil
+		}
+		return nil, nil, err
+	}
+
+	root := &doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("line %d: expected a mapping of keys to values", root.Line)
+	}
+
+	envVars := make(map[string]string, len(root.Content)/2)
+	lines := make(map[string]int, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		if value.Kind != yaml.ScalarNode {
+			return nil, nil, fmt.Errorf("line %d: %s: nested values are not supported", value.Line, key.Value)
+		}
+		lines[key.Value] = key.Line
+		switch {
+		case value.Tag == "!!null":
+			envVars[key.Value] = ""
+		case value.Tag == vaultTag && vaultPassword != "":
+			decrypted, err := decryptVault(value.Value, vaultPassword)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %s: %w", value.Line, key.Value, err)
+			}
+			envVars[key.Value] = decrypted
+		default:
+			envVars[key.Value] = value.Value
+		}
+	}
+	return envVars, lines, nil
+}
+
+// decodeTOML reads a TOML document. A document holding only an [env] table is read as if its keys
+// were at the root, other tables are flattened.
+func decodeTOML(r io.Reader) (map[string]string, error) {
+	var values map[string]any
+	if _, err := toml.NewDecoder(r).Decode(&values); err != nil {
+		return nil, err
+	}
+	if env, ok := values["env"].(map[string]any); ok && len(values) == 1 {
+		values = env
+	}
+	return scalarValues(values)
+}
+
+// tomlTimeLayouts maps the zone names BurntSushi/toml uses for local dates and times to their layout.
+// Other times are formatted as RFC 3339.
+var tomlTimeLayouts = map[string]string{
+	"datetime-local": "2006-01-02T15:04:05.999999999",
+	"date-local":     time.DateOnly,
+	"time-local":     "15:04:05.999999999",
+}
+
+// scalarValues converts decoded values to strings. Nested objects are flattened by joining the keys
+// with an underscore, e.g. {"db": {"host": "x"}} becomes db_host=x. Arrays are an error.
+func scalarValues(values map[string]any) (map[string]string, error) {
+	envVars := make(map[string]string, len(values))
+	if err := flattenValues(envVars, "", values); err != nil {
+		return nil, err
+	}
+	return envVars, nil
+}
+
+// flattenValues adds the values to envVars with their keys prefixed by prefix.
+func flattenValues(envVars map[string]string, prefix string, values map[string]any) error {
+	for key, value := range values {
+		key = prefix + key
+		switch v := value.(type) {
+		case nil:
+			envVars[key] = ""
+		case string:
+			envVars[key] = v
+		case json.Number, bool, int64, float64:
+			envVars[key] = fmt.Sprint(v)
+		case time.Time:
+			layout, ok := tomlTimeLayouts[v.Location().String()]
+			if !ok {
+				layout = time.RFC3339Nano
+			}
+			envVars[key] = v.Format(layout)
+		case map[string]any:
+			if err := flattenValues(envVars, key+"_", v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: arrays are not supported", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,646 @@
+package envparse
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Parse reads env file content from r into a map with support for comments and multiline values.
+// References are kept as written, use Expand to expand them.
+func Parse(r io.Reader, opts ...Option) (map[string]string, error) {
+	parsed, err := parse(r, "", newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return parsed.vars, nil
+}
+
+// ParseFile reads the env file at filePath into a map.
+func ParseFile(filePath string, opts ...Option) (map[string]string, error) {
+	parsed, err := parseEnvFile(filePath, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return parsed.vars, nil
+}
+
+// parsedFile holds the content of a single parsed env file.
+type parsedFile struct {
+	name       string
+	vars       map[string]string
+	priorities map[string]overridePriority
+	// lines holds the line number each variable was defined on, ends the last line of the definition
+	lines map[string]int
+	ends  map[string]int
+	// quotes holds the quote around the value of each variable, if any
+	quotes map[string]string
+	// unsets holds the variables removed with unset directives, with the line of the directive
+	unsets map[string]int
+	// origins holds where the variables taken from included files were defined. Their lines hold
+	// the line of the include directive.
+	origins map[string]location
+	issues  []Issue
+}
+
+// location is the file and line a variable was defined on.
+type location struct {
+	file string
+	line int
+}
+
+// parseEnvFile reads an env file and returns its variables and override priorities.
+func parseEnvFile(filePath string, o *options) (*parsedFile, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// nolint: errcheck
+	defer file.Close()
+
+	if err := checkPermissions(file, o); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	return parseFormat(file, filePath, fileFormat(filePath, o), o)
+}
+
+// fileFormat returns the format of the env file name, which is the one set with WithFormat or
+// detected by the extension of name.
+func fileFormat(name string, o *options) Format {
+	if o.format != "" {
+		return o.format
+	}
+	return DetectFormat(name)
+}
+
+// parseFormat reads an env file in the given format. Variables from JSON and TOML files don't have
+// line numbers and are ordered by key.
+func parseFormat(r io.Reader, name string, format Format, o *options) (*parsedFile, error) {
+	if format == FormatDotenv {
+		return parse(r, name, o)
+	}
+
+	var (
+		vars  map[string]string
+		lines = make(map[string]int)
+		err   error
+	)
+	if format == FormatYAML {
+		vars, lines, err = decodeYAML(r, o.vaultPassword)
+	} else {
+		vars, err = Decode(r, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &parsedFile{
+		name:       name,
+		vars:       vars,
+		priorities: make(map[string]overridePriority),
+		lines:      lines,
+		ends:       make(map[string]int),
+		quotes:     make(map[string]string),
+		unsets:     make(map[string]int),
+		origins:    make(map[string]location),
+	}, nil
+}
+
+// checkPermissions warns if other users can read the env file, which often holds secrets. With strict
+// permissions this is an error. Windows doesn't have Unix permissions, so nothing is checked there.
+func checkPermissions(file *os.File, o *options) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode().Perm()
+	if mode&0o004 == 0 {
+		return nil
+	}
+	if o.strictPermissions {
+		return fmt.Errorf("file is readable by other users (mode %04o)", mode)
+	}
+	o.warning(Issue{
+		File:     file.Name(),
+		Rule:     RulePermissions,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("file is readable by other users (mode %04o), consider chmod 600", mode),
+	})
+	return nil
+}
+
+// overridePriority controls how a variable behaves when it is defined in multiple env files.
+type overridePriority string
+
+const (
+	// priorityAlways makes a variable override previous files even without WithOverride.
+	priorityAlways overridePriority = "always"
+	// priorityNever prevents a variable from being overridden by succeeding files.
+	priorityNever overridePriority = "never"
+)
+
+var exportPrefix = regexp.MustCompile(`^export\s+`)
+
+var overrideAnnotation = regexp.MustCompile(`^#\s*exportenv:\s*override-priority\s*=\s*(\S*)\s*$`)
+
+// parseOverrideAnnotation parses a "# exportenv: override-priority=..." comment line.
+// It returns false if the line is not an annotation.
+func parseOverrideAnnotation(line string) (overridePriority, bool, error) {
+	matches := overrideAnnotation.FindStringSubmatch(line)
+	if matches == nil {
+		return "", false, nil
+	}
+	switch priority := overridePriority(matches[1]); priority {
+	case priorityAlways, priorityNever:
+		return priority, true, nil
+	default:
+		return "", false, fmt.Errorf("invalid override-priority %q, expected always or never", matches[1])
+	}
+}
+
+var (
+	unsetDirective   = regexp.MustCompile(`^unset\s+(.+)$`)
+	includeDirective = regexp.MustCompile(`^(?:source|include)\s+([^\s=].*)$`)
+	variableName     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// maxIncludeDepth limits how deeply include directives can be nested.
+const maxIncludeDepth = 10
+
+// parseUnsetDirective returns the variables named by an "unset KEY..." line.
+func parseUnsetDirective(line string) ([]string, bool) {
+	matches := unsetDirective.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, false
+	}
+	return strings.Fields(removeInlineComment(matches[1])), true
+}
+
+// parseIncludeDirective returns the file named by a "source FILE" or "include FILE" line.
+func parseIncludeDirective(line string) (string, bool) {
+	matches := includeDirective.FindStringSubmatch(line)
+	if matches == nil {
+		return "", false
+	}
+	path := removeInlineComment(matches[1])
+	if len(path) >= 2 && (path[0] == '"' || path[0] == '\'') && path[len(path)-1] == path[0] {
+		path = path[1 : len(path)-1]
+	}
+	return path, true
+}
+
+// parse reads env file content into a map with support for comments and multiline values. It also
+// records the override priorities annotated on the variables, their line numbers and issues found in
+// the file. The name is used to report issues.
+func parse(r io.Reader, name string, o *options) (*parsedFile, error) {
+	parsed := &parsedFile{
+		name:       name,
+		vars:       make(map[string]string),
+		priorities: make(map[string]overridePriority),
+		lines:      make(map[string]int),
+		ends:       make(map[string]int),
+		quotes:     make(map[string]string),
+		unsets:     make(map[string]int),
+		origins:    make(map[string]location),
+	}
+
+	var (
+		key       string
+		value     string
+		multiline bool
+		quote     string
+		// block holds the lines of a verbatim ``` block so far
+		block []string
+		// priority is applied to the variable following an annotation
+		priority overridePriority
+		lineNum  int
+		// startLine is the line the current variable started on
+		startLine int
+		// continued holds the lines joined with a trailing backslash so far
+		continued  string
+		continuing bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+
+		// Lines of a verbatim block are taken as they are, up to the closing backticks
+		if multiline && quote == verbatimQuote {
+			var closed bool
+			if block, closed = appendVerbatimLine(block, scanner.Text()); closed {
+				parsed.set(key, strings.Join(block, "\n"), quote, startLine, lineNum)
+				multiline = false
+			}
+			continue
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if continuing {
+			line = continued + line
+			continuing = false
+		} else if !multiline {
+			startLine = lineNum
+			p, ok, err := parseOverrideAnnotation(line)
+			if err != nil {
+				return nil, lineError(name, startLine, err)
+			}
+			if ok {
+				priority = p
+				continue
+			}
+		}
+
+		// Ignore comment or empty lines
+		if isCommentOrEmpty(line) {
+			continue
+		}
+
+		// Handle multiline values continuation
+		if multiline {
+			// Check if the multiline value ends on this line
+			if strings.HasSuffix(line, quote) {
+				// Remove trailing quote and add the line to the multiline value
+				value += "\n" + strings.TrimSuffix(line, quote)
+				// Remove any inline comment after the closing quote
+				value = removeInlineComment(value)
+				parsed.set(key, value, quote, startLine, lineNum)
+				multiline = false
+			} else {
+				// Continue adding to the multiline value
+				value += "\n" + line
+			}
+			continue
+		}
+
+		// Join lines ending with a backslash with the next line, without a newline
+		if o.continuesLine(line) {
+			continued, continuing = strings.TrimSuffix(line, `\`), true
+			continue
+		}
+
+		line, err := trimExport(line, o.strict)
+		if err != nil {
+			return nil, lineError(name, startLine, err)
+		}
+		isDirective, err := parsed.directive(line, startLine, o)
+		if err != nil {
+			return nil, lineError(name, startLine, err)
+		}
+		if isDirective {
+			continue
+		}
+
+		// Parse line to get key, value, and multiline start
+		var val string
+		key, val, multiline, quote = parseLine(line)
+		if key == "" {
+			parsed.addIssue(startLine, RuleSyntax, SeverityError, fmt.Sprintf("invalid line %q, expected KEY=VALUE", line))
+			continue
+		}
+		parsed.annotate(key, priority)
+		priority = ""
+		if multiline {
+			value, block = val, verbatimStart(val)
+			continue
+		}
+
+		// Store key-value pair. References are expanded by LoadEntries, which also sees the variables
+		// of other files, sources and vars.
+		parsed.set(key, val, quote, startLine, lineNum)
+	}
+
+	if err := scanner.Err(); err != nil {
+		// The scanner stopped at the line following the last one read
+		return nil, lineError(name, lineNum+1, err)
+	}
+	if message := unterminated(key, multiline, continuing); message != "" {
+		parsed.addIssue(startLine, RuleSyntax, SeverityError, message)
+	}
+	return parsed, nil
+}
+
+// unterminated returns the message for a quoted value of key or a line continuation that is still open at
+// the end of the file, or an empty string if neither is.
+func unterminated(key string, multiline, continuing bool) string {
+	switch {
+	case multiline:
+		return fmt.Sprintf("unterminated quoted value of %s", key)
+	case continuing:
+		return "unterminated line continuation at end of file"
+	}
+	return ""
+}
+
+// trimExport removes an export prefix from line, which is accepted for lines copied from shell scripts
+// unless in strict mode.
+func trimExport(line string, strict bool) (string, error) {
+	if !exportPrefix.MatchString(line) {
+		return line, nil
+	}
+	if strict {
+		return "", errors.New("export prefix is not allowed in strict mode")
+	}
+	return exportPrefix.ReplaceAllString(line, ""), nil
+}
+
+// annotate applies the override priority of an annotation to the variable key. Without an annotation,
+// priority is empty and a priority annotated on an earlier definition is kept.
+func (p *parsedFile) annotate(key string, priority overridePriority) {
+	if priority != "" {
+		p.priorities[key] = priority
+	}
+}
+
+// verbatimStart returns the lines of a verbatim ``` block starting with val, the text following the
+// opening backticks on the line of the key.
+func verbatimStart(val string) []string {
+	if strings.TrimSpace(val) == "" {
+		return nil
+	}
+	return []string{val}
+}
+
+// appendVerbatimLine appends a line of a verbatim ``` block to block, without trailing whitespace, and
+// reports whether the line closes the block. The closing backticks are not part of the value, and
+// neither is the closing line if nothing precedes them.
+func appendVerbatimLine(block []string, line string) ([]string, bool) {
+	content, closed := strings.CutSuffix(strings.TrimRight(line, " \t"), verbatimQuote)
+	if !closed || strings.TrimSpace(content) != "" {
+		block = append(block, content)
+	}
+	return block, closed
+}
+
+// directive handles an unset or include directive on the given line and reports whether the line is
+// one. Directives are ignored with WithNoDirectives.
+func (p *parsedFile) directive(line string, lineNum int, o *options) (bool, error) {
+	if o.noDirectives {
+		return false, nil
+	}
+
+	// Remove variables defined before, also by previous files
+	if keys, ok := parseUnsetDirective(line); ok {
+		for _, k := range keys {
+			if !variableName.MatchString(k) {
+				p.addIssue(lineNum, RuleSyntax, SeverityError, fmt.Sprintf("invalid variable name %q in unset directive", k))
+				continue
+			}
+			p.unset(k, lineNum)
+		}
+		return true, nil
+	}
+
+	// Load another file as if its variables were defined on this line
+	if path, ok := parseIncludeDirective(line); ok {
+		if o.noIncludes {
+			return true, nil
+		}
+		return true, p.include(path, lineNum, o)
+	}
+	return false, nil
+}
+
+// lineError prefixes err with the location it was found at, as name:line. Without a name, as when
+// parsing with Parse, the line is reported as "line N".
+func lineError(name string, line int, err error) error {
+	if name == "" {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+	return fmt.Errorf("%s:%d: %w", name, line, err)
+}
+
+// set stores a variable whose value was enclosed in quote, defined from line to end, recording duplicate
+// definitions as issues. Overriding a variable from an included file is not a duplicate.
+func (p *parsedFile) set(key, value, quote string, line, end int) {
+	_, included := p.origins[key]
+	if prev, exists := p.lines[key]; exists && !included && key != "" {
+		p.addIssue(line, RuleDuplicateKeys, SeverityWarning, fmt.Sprintf("duplicate key %s, previously defined on line %d", key, prev))
+	}
+	p.vars[key] = value
+	p.lines[key] = line
+	p.ends[key] = end
+	p.quotes[key] = quote
+	delete(p.origins, key)
+}
+
+// unset removes a variable defined before the given line and records the removal for previous files.
+func (p *parsedFile) unset(key string, line int) {
+	delete(p.vars, key)
+	delete(p.lines, key)
+	delete(p.ends, key)
+	delete(p.quotes, key)
+	delete(p.priorities, key)
+	delete(p.origins, key)
+	p.unsets[key] = line
+}
+
+// include loads the env file at path as if its variables were defined on the given line. Relative
+// paths are resolved from the directory of the including file.
+func (p *parsedFile) include(path string, line int, o *options) error {
+	if isURL(p.name) {
+		return errors.New("include directives are not supported in remote env files")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.name), path)
+	}
+
+	chain := o.including
+	if len(chain) == 0 {
+		chain = []string{p.name}
+	}
+	if len(chain) > maxIncludeDepth {
+		return fmt.Errorf("includes are nested more than %d levels deep", maxIncludeDepth)
+	}
+	for _, name := range chain {
+		if sameFile(name, path) {
+			return fmt.Errorf("circular include: %s", strings.Join(append(slices.Clone(chain), path), " -> "))
+		}
+	}
+
+	prev := o.including
+	o.including = append(slices.Clip(chain), path)
+	included, err := parseEnvFile(path, o)
+	o.including = prev
+	if err != nil {
+		if o.ignoreMissing && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for k := range included.unsets {
+		p.unset(k, line)
+	}
+	for _, k := range included.keys() {
+		file, l := included.location(k)
+		p.vars[k] = included.vars[k]
+		p.lines[k] = line
+		p.ends[k] = line
+		p.quotes[k] = included.quotes[k]
+		p.origins[k] = location{file: file, line: l}
+		if priority, ok := included.priorities[k]; ok {
+			p.priorities[k] = priority
+		}
+	}
+	p.issues = append(p.issues, included.issues...)
+	return nil
+}
+
+// sameFile reports whether the paths name the same existing file.
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	return err == nil && os.SameFile(infoA, infoB)
+}
+
+// location returns the file and line the variable was defined on, following include directives.
+func (p *parsedFile) location(key string) (string, int) {
+	if origin, ok := p.origins[key]; ok {
+		return origin.file, origin.line
+	}
+	return p.name, p.lines[key]
+}
+
+// keys returns the keys of the file in the order they were defined.
+func (p *parsedFile) keys() []string {
+	keys := make([]string, 0, len(p.vars))
+	for k := range p.vars {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if p.lines[keys[i]] != p.lines[keys[j]] {
+			return p.lines[keys[i]] < p.lines[keys[j]]
+		}
+		// Variables from the same include directive keep the order of the included file
+		if p.origins[keys[i]].line != p.origins[keys[j]].line {
+			return p.origins[keys[i]].line < p.origins[keys[j]].line
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// addIssue records an issue found on the given line by the given rule.
+func (p *parsedFile) addIssue(line int, rule string, severity Severity, message string) {
+	p.issues = append(p.issues, Issue{File: p.name, Line: line, Rule: rule, Severity: severity, Message: message})
+}
+
+// verbatimQuote starts and ends a multiline value whose lines are taken as they are.
+const verbatimQuote = "```"
+
+// isLiteralQuote reports whether values enclosed in quote are taken literally, without expanding
+// references. This is the case for backticks and verbatim blocks.
+func isLiteralQuote(quote string) bool {
+	return quote == "`" || quote == verbatimQuote
+}
+
+// parseLine parses a line and returns the key, value, whether it is a multiline start and the quote
+// around the value, if any. Values in double quotes, single quotes, backticks or triple backticks are
+// supported; only values in triple backticks are kept as written, including spaces and comments.
+func parseLine(line string) (string, string, bool, string) {
+	keyValueLine := regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+	matches := keyValueLine.FindStringSubmatch(line)
+	if matches == nil {
+		return "", "", false, ""
+	}
+
+	key, val := matches[1], matches[2]
+
+	if rest, ok := strings.CutPrefix(val, verbatimQuote); ok {
+		if content, closed := strings.CutSuffix(rest, verbatimQuote); closed {
+			return key, content, false, verbatimQuote
+		}
+		return key, rest, true, verbatimQuote
+	}
+
+	// Remove inline comments if outside quotes
+	val = removeInlineComment(val)
+
+	// Check for quoted values (double, single or backticks)
+	if val != "" && strings.ContainsRune("\"'`", rune(val[0])) {
+		quote := val[:1]
+		val = strings.TrimPrefix(val, quote)
+
+		// Check if it's a single-line quoted value by verifying it ends with the same quote
+		if strings.HasSuffix(val, quote) {
+			val = strings.TrimSuffix(val, quote)
+			return key, val, false, quote // Single-line quoted value
+		}
+
+		// Start of a multiline quoted value
+		return key, val, true, quote
+	}
+
+	// Unquoted single-line value
+	return key, val, false, ""
+}
+
+// continuesLine reports whether line is joined with the next line, because it ends with an unescaped
+// backslash and joining lines isn't disabled with WithNoBackslashContinue.
+func (o *options) continuesLine(line string) bool {
+	return !o.noBackslashContinue && endsWithContinuation(line)
+}
+
+// endsWithContinuation checks if a line ends with an unescaped backslash.
+func endsWithContinuation(line string) bool {
+	trailing := len(line) - len(strings.TrimRight(line, `\`))
+	return trailing%2 == 1
+}
+
+// isCommentOrEmpty checks if a line is a comment or empty.
+func isCommentOrEmpty(line string) bool {
+	return line == "" || strings.HasPrefix(line, "#")
+}
+
+// removeInlineComment removes inline comments if not inside quotes. A # inside a ${...} or $(...)
+// reference is an operator, as in ${VAR#prefix}, and doesn't start a comment.
+func removeInlineComment(val string) string {
+	var result strings.Builder
+	inQuote := false
+	quoteChar := rune(0)
+	// closers holds the closing brackets of the references the current character is in
+	var closers []rune
+	prev := rune(0)
+
+	for _, char := range val {
+		if (char == '"' || char == '\'' || char == '`') && !inQuote {
+			// Starting a quoted section
+			inQuote = true
+			quoteChar = char
+		} else if char == quoteChar && inQuote {
+			// Ending a quoted section
+			inQuote = false
+		} else if prev == '$' && char == '{' && !inQuote {
+			closers = append(closers, '}')
+		} else if prev == '$' && char == '(' && !inQuote {
+			closers = append(closers, ')')
+		} else if len(closers) > 0 && char == closers[len(closers)-1] && !inQuote {
+			closers = closers[:len(closers)-1]
+		} else if char == '#' && !inQuote && len(closers) == 0 {
+			// Found a comment outside quotes; ignore the rest of the line
+			break
+		}
+		result.WriteRune(char)
+		prev = char
+	}
+
+	return strings.TrimSpace(result.String())
+}
@@ -0,0 +1,150 @@
+package envparse
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the env files to a temporary directory and returns their paths in order.
+func writeFiles(t testing.TB, contents ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	files := make([]string, len(contents))
+	for i, content := range contents {
+		files[i] = filepath.Join(dir, string(rune('a'+i))+".env")
+		if err := os.WriteFile(files[i], []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestParseQuotedValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "double quotes", input: `V="a b"`, want: "a b"},
+		{name: "double quotes keep references", input: `V="${A} world"`, want: "${A} world"},
+		{name: "double quotes keep backslashes", input: `V="C:\path\n"`, want: `C:\path\n`},
+		{name: "single quotes", input: `V='a # b'`, want: "a # b"},
+		{name: "backticks", input: "V=`a \"b\"`", want: `a "b"`},
+		{name: "triple backticks", input: "V=```\n  a\n# b\n```", want: "  a\n# b"},
+		{name: "multiline", input: "V=\"a\nb\"", want: "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got["V"] != tt.want {
+				t.Errorf("V = %q, want %q", got["V"], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLiteralValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "backticks", input: "V=`$H x`", want: "$H x"},
+		{name: "triple backticks", input: "V=```\n${H}\n  $H\n```", want: "${H}\n  $H"},
+		{name: "referenced", input: "L=`$H x`\nV=\"${L} y\"", want: "$H x y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVars, err := Load(writeFiles(t, "H=home\n"+tt.input+"\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if envVars["V"] != tt.want {
+				t.Errorf("V = %q, want %q", envVars["V"], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDoubleQuotedReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []string
+		opts     []Option
+		want     string
+	}{
+		{
+			name:     "previous file",
+			contents: []string{"A=hello\n", "B=\"${A} world\"\n"},
+			want:     "hello world",
+		},
+		{
+			name:     "later line",
+			contents: []string{"B=\"${A} world\"\nA=hello\n"},
+			want:     "hello world",
+		},
+		{
+			name:     "vars",
+			contents: []string{"B=\"${A:?A is required} world\"\n"},
+			opts:     []Option{WithVars(map[string]string{"A": "hello"})},
+			want:     "hello world",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVars, err := Load(writeFiles(t, tt.contents...), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if envVars["B"] != tt.want {
+				t.Errorf("B = %q, want %q", envVars["B"], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInlineComments(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "V=a # comment", want: "a"},
+		{input: "V=a#comment", want: "a"},
+		{input: "V=\"a # b\" # comment", want: "a # b"},
+		{input: "V=${B#fall} # comment", want: "${B#fall}"},
+		{input: "V=${B##*/}", want: "${B##*/}"},
+		{input: "V=${B:-${C#x}}#comment", want: "${B:-${C#x}}"},
+		{input: "V=$(B#fall) # comment", want: "$(B#fall)"},
+		{input: "V={a} # comment", want: "{a}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got["V"] != tt.want {
+				t.Errorf("V = %q, want %q", got["V"], tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTrimOperatorsUnquoted(t *testing.T) {
+	files := writeFiles(t, "B=fallback/x.tar.gz\nE=${B#fall} # comment\nF=${B##*/}\nG=${B%%.*}\n")
+	envVars, err := Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"E": "back/x.tar.gz", "F": "x.tar.gz", "G": "fallback/x"}
+	for k, v := range want {
+		if envVars[k] != v {
+			t.Errorf("%s = %q, want %q", k, envVars[k], v)
+		}
+	}
+}
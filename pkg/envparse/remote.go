@@ -0,0 +1,102 @@
+package envparse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPTimeout is the timeout for fetching an env file over HTTP if no client is configured.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// retryDelay is the delay between attempts, multiplied by the attempt number.
+var retryDelay = time.Second
+
+// isURL reports whether an env file name is an HTTP or HTTPS URL.
+func isURL(file string) bool {
+	return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
+
+// parseURL fetches an env file over HTTP and parses it.
+func parseURL(url string, o *options) (*parsedFile, error) {
+	body, err := fetch(url, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect the format by the extension of the path, ignoring query parameters
+	path := url
+	if u, err := neturl.Parse(url); err == nil {
+		path = u.Path
+	}
+	return parseFormat(bytes.NewReader(body), url, fileFormat(path, o), o)
+}
+
+// fetch downloads url, retrying network errors and transient HTTP status codes.
+// A 404 response is reported as fs.ErrNotExist. The bearer token is only sent over HTTPS, fetching
+// a plain HTTP URL with a token is an error.
+func fetch(url string, o *options) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= o.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * retryDelay)
+		}
+
+		body, retry, err := fetchOnce(url, o)
+		if err == nil {
+			return body, nil
+		}
+		if !retry {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// fetchOnce downloads url and reports whether a failed request should be retried.
+func fetchOnce(url string, o *options) ([]byte, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if o.token != "" {
+		if req.URL.Scheme != "https" {
+			return nil, false, fmt.Errorf("%s: refusing to send the bearer token over plain HTTP", url)
+		}
+		req.Header.Set("Authorization", "Bearer "+o.token)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	// nolint: errcheck
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, fmt.Errorf("%s: %w", url, fs.ErrNotExist)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return nil, true, fmt.Errorf("%s: %s", url, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, false, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// A connection that is closed or times out while reading the body is retried like one that fails
+		// before the response
+		var netErr net.Error
+		retry := errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+		return nil, retry, fmt.Errorf("%s: %w", url, err)
+	}
+	return body, false, nil
+}
@@ -0,0 +1,125 @@
+package envparse
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// serveResponses starts a server that answers the n-th request with the n-th response, repeating the
+// last one, and returns the server and the number of requests it received.
+func serveResponses(t *testing.T, tls bool, responses ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	delay := retryDelay
+	retryDelay = 0
+	t.Cleanup(func() { retryDelay = delay })
+
+	var requests atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		responses[min(n, len(responses))-1](w, r)
+	})
+	server := httptest.NewUnstartedServer(handler)
+	if tls {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// status returns a response with the given status code.
+func status(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+// content returns a response with an env file.
+func content(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte("A=remote\n"))
+}
+
+// truncated returns a response whose body is shorter than announced.
+func truncated(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Length", "100")
+	_, _ = w.Write([]byte("A=rem"))
+}
+
+func TestLoadURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []http.HandlerFunc
+		requests  int32
+		wantErr   string
+	}{
+		{name: "ok", responses: []http.HandlerFunc{content}, requests: 1},
+		{name: "server error", responses: []http.HandlerFunc{status(502), content}, requests: 2},
+		{name: "too many requests", responses: []http.HandlerFunc{status(429), content}, requests: 2},
+		{name: "truncated body", responses: []http.HandlerFunc{truncated, content}, requests: 2},
+		{name: "retries exhausted", responses: []http.HandlerFunc{status(503)}, requests: 3, wantErr: "503 Service Unavailable"},
+		{name: "client error", responses: []http.HandlerFunc{status(403)}, requests: 1, wantErr: "403 Forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := serveResponses(t, false, tt.responses...)
+			envVars, err := Load([]string{server.URL + "/app.env"}, WithRetries(2))
+			switch {
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			case tt.wantErr == "" && err != nil:
+				t.Error(err)
+			case tt.wantErr == "" && envVars["A"] != "remote":
+				t.Errorf("A = %q, want %q", envVars["A"], "remote")
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("got %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestLoadURLNotFound(t *testing.T) {
+	server, requests := serveResponses(t, false, status(http.StatusNotFound))
+	url := server.URL + "/app.env"
+
+	if _, err := Load([]string{url}, WithRetries(2)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got error %v, want fs.ErrNotExist", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+	if _, err := Load([]string{url}, WithIgnoreMissing(true)); err != nil {
+		t.Errorf("missing file with WithIgnoreMissing: %v", err)
+	}
+}
+
+func TestLoadURLBearerToken(t *testing.T) {
+	var auth string
+	server, _ := serveResponses(t, true, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		content(w, r)
+	})
+	if _, err := Load([]string{server.URL + "/app.env"}, WithHTTPClient(server.Client()), WithBearerToken("s3cr3t")); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer s3cr3t" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer s3cr3t")
+	}
+}
+
+func TestLoadURLBearerTokenOverHTTP(t *testing.T) {
+	server, requests := serveResponses(t, false, content)
+	_, err := Load([]string{server.URL + "/app.env"}, WithBearerToken("s3cr3t"))
+	if err == nil || !strings.Contains(err.Error(), "plain HTTP") {
+		t.Errorf("got error %v, want the token to be refused", err)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("got %d requests, want none", got)
+	}
+}
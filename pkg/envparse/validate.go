@@ -0,0 +1,107 @@
+package envparse
+
+import (
+	"fmt"
+)
+
+// Severity classifies an Issue.
+type Severity string
+
+const (
+	// SeverityError marks issues that make an env file invalid.
+	SeverityError Severity = "error"
+	// SeverityWarning marks suspicious but valid content.
+	SeverityWarning Severity = "warning"
+)
+
+// Rules name the checks that find issues in env files.
+const (
+	// RuleSyntax reports lines that can't be parsed.
+	RuleSyntax = "syntax"
+	// RuleDuplicateKeys reports keys defined more than once in a file.
+	RuleDuplicateKeys = "duplicate-keys"
+	// RulePermissions reports files that other users can read.
+	RulePermissions = "permissions"
+	// RuleUndefinedReferences reports references to variables that are defined nowhere.
+	RuleUndefinedReferences = "undefined-references"
+)
+
+// Issue is a problem found while validating env files.
+type Issue struct {
+	File string
+	Line int
+	// Rule names the check that found the issue, it is empty for issues found while merging files
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+// String formats the issue as file:line: severity: message. The line is left out for issues
+// concerning the whole file.
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", i.File, i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", i.File, i.Line, i.Severity, i.Message)
+}
+
+// Validate parses env files like Load and reports syntax errors and duplicate keys. Unless expansion is
+// disabled, references to variables that are defined nowhere are reported as errors as well.
+// The returned error is only set if a file can't be read.
+func Validate(files []string, opts ...Option) ([]Issue, error) {
+	o := newOptions(opts)
+	parsedFiles, err := parseEnvFiles(files, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	defined := make(map[string]bool)
+	for _, parsed := range parsedFiles {
+		issues = append(issues, parsed.issues...)
+		for k := range parsed.vars {
+			defined[k] = true
+		}
+	}
+	for _, source := range o.sources {
+		for k := range source {
+			defined[k] = true
+		}
+	}
+	for k := range o.vars {
+		defined[k] = true
+	}
+
+	if !o.noExpand {
+		for _, parsed := range parsedFiles {
+			issues = append(issues, undefinedReferences(parsed, defined, o.interpolation)...)
+		}
+	}
+	return issues, nil
+}
+
+// undefinedReferences reports the variables referenced in a parsed file that are not defined.
+// References with a default value, alternative or error message are not reported.
+func undefinedReferences(parsed *parsedFile, defined map[string]bool, syntax Interpolation) []Issue {
+	var issues []Issue
+	for _, key := range parsed.keys() {
+		if isLiteralQuote(parsed.quotes[key]) {
+			continue
+		}
+		file, line := parsed.location(key)
+		expandSyntax(parsed.vars[key], syntax, func(expr string) string {
+			name, operator, _ := splitParameter(expr)
+			if (operator == "" || isTrimOperator(operator)) && !defined[name] {
+				issues = append(issues, Issue{
+					File:     file,
+					Line:     line,
+					Rule:     RuleUndefinedReferences,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s references undefined variable %s", key, name),
+				})
+			}
+			return ""
+		})
+	}
+	return issues
+}
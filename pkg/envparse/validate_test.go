@@ -0,0 +1,44 @@
+package envparse
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateUndefinedReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []string
+		opts     []Option
+		want     []string
+	}{
+		{name: "unquoted", contents: []string{"X=${UNDEFINED}/path\n"}, want: []string{"X references undefined variable UNDEFINED"}},
+		{name: "double-quoted", contents: []string{"X=\"${UNDEFINED}/path\"\n"}, want: []string{"X references undefined variable UNDEFINED"}},
+		{name: "multiline", contents: []string{"X=\"a\n$UNDEFINED\"\n"}, want: []string{"X references undefined variable UNDEFINED"}},
+		{name: "trim operator", contents: []string{"X=\"${UNDEFINED#a}\"\n"}, want: []string{"X references undefined variable UNDEFINED"}},
+		{name: "defined in another file", contents: []string{"A=a\n", "X=\"${A}/path\"\n"}},
+		{name: "defined later", contents: []string{"X=\"${A}/path\"\nA=a\n"}},
+		{name: "defined by vars", contents: []string{"X=\"${A}/path\"\n"}, opts: []Option{WithVars(map[string]string{"A": "a"})}},
+		{name: "default", contents: []string{"X=\"${UNDEFINED:-a}\"\n"}},
+		{name: "backticks", contents: []string{"X=`${UNDEFINED}`\n"}},
+		{name: "triple backticks", contents: []string{"X=```\n${UNDEFINED}\n```\n"}},
+		{name: "no expand", contents: []string{"X=\"${UNDEFINED}\"\n"}, opts: []Option{WithNoExpand(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := Validate(writeFiles(t, tt.contents...), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, issue := range issues {
+				if issue.Rule == RuleUndefinedReferences {
+					got = append(got, issue.Message)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
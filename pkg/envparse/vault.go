@@ -0,0 +1,99 @@
+package envparse
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// vaultTag marks YAML values encrypted with Ansible Vault.
+const vaultTag = "!vault"
+
+// vaultIterations is the number of PBKDF2 iterations Ansible Vault uses to derive its keys.
+const vaultIterations = 10000
+
+// decryptVault decrypts a value encrypted with Ansible Vault in the 1.1 or 1.2 format, using AES-256 in
+// CTR mode with keys derived from password, and verifies its HMAC.
+func decryptVault(text, password string) (string, error) {
+	header, body, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	fields := strings.Split(strings.TrimSpace(header), ";")
+	if len(fields) < 3 || fields[0] != "$ANSIBLE_VAULT" || fields[2] != "AES256" {
+		return "", errors.New("not an Ansible Vault AES256 value")
+	}
+	if fields[1] != "1.1" && fields[1] != "1.2" {
+		return "", fmt.Errorf("unsupported Ansible Vault version %s", fields[1])
+	}
+
+	// The body is the hex encoding of the hex-encoded salt, HMAC and ciphertext on separate lines
+	envelope, err := hex.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return "", fmt.Errorf("invalid Ansible Vault value: %w", err)
+	}
+	parts := strings.Split(string(envelope), "\n")
+	if len(parts) != 3 {
+		return "", errors.New("invalid Ansible Vault value")
+	}
+	var decoded [3][]byte
+	for i, part := range parts {
+		if decoded[i], err = hex.DecodeString(part); err != nil {
+			return "", fmt.Errorf("invalid Ansible Vault value: %w", err)
+		}
+	}
+	salt, mac, ciphertext := decoded[0], decoded[1], decoded[2]
+
+	derived := pbkdf2SHA256([]byte(password), salt, vaultIterations, 80)
+	cipherKey, hmacKey, iv := derived[:32], derived[32:64], derived[64:]
+	h := hmac.New(sha256.New, hmacKey)
+	h.Write(ciphertext)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return "", errors.New("wrong Ansible Vault password or corrupted value")
+	}
+
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+
+	// The plaintext is padded with PKCS#7 to the AES block size
+	if len(plaintext) == 0 {
+		return "", errors.New("invalid Ansible Vault padding")
+	}
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(plaintext) ||
+		!bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return "", errors.New("invalid Ansible Vault padding")
+	}
+	return string(plaintext[:len(plaintext)-padding]), nil
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password and salt with PBKDF2 and HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := bytes.Clone(u)
+		for range iterations - 1 {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,57 @@
+package envparse
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// vaultValue is "hunter2: s3cr3t value" encrypted with the password "correct horse" in the Ansible
+// Vault 1.1 format, with the salt 0x00 to 0x1f. It was created with Python's hashlib and OpenSSL
+// following the format of Ansible's VaultAES256.
+const vaultValue = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a643365333333336365616661343934
+31616233373066333136343263353062666639373365656464623036666166663561366566633535
+3937366165643661610a613365363762376362623765666236343630383731303232616239613639
+37633335353738666664643438336361346464613436393930656262646537366639
+`
+
+func TestDecryptVault(t *testing.T) {
+	got, err := decryptVault(vaultValue, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hunter2: s3cr3t value"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := decryptVault(vaultValue, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong Ansible Vault password") {
+		t.Errorf("wrong password: got error %v", err)
+	}
+	if _, err := decryptVault(strings.Replace(vaultValue, "1.1", "2.0", 1), "correct horse"); err == nil {
+		t.Error("unsupported version: expected an error")
+	}
+}
+
+func TestLoadYAMLVault(t *testing.T) {
+	indented := strings.ReplaceAll(strings.TrimSpace(vaultValue), "\n", "\n  ")
+	path := writeFiles(t, "PLAIN: a\nSECRET: !vault |\n  "+indented+"\n")[0]
+	envVars, err := Load([]string{path}, WithFormat(FormatYAML), WithVaultPassword("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envVars["PLAIN"] != "a" || envVars["SECRET"] != "hunter2: s3cr3t value" {
+		t.Errorf("got %q", envVars)
+	}
+}
+
+// TestPBKDF2SHA256 checks the key derivation against the PBKDF2-HMAC-SHA256 test vector of RFC 7914.
+func TestPBKDF2SHA256(t *testing.T) {
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package envparse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Write writes the environment variables to w as an env file with one KEY=VALUE line per variable,
+// sorted by key. Values are quoted where necessary, so parsing the output yields the same values.
+// References in values are kept and expanded when the file is loaded. Values that can't be represented
+// in an env file, such as values containing both kinds of quotes and a line break, are an error.
+func Write(w io.Writer, envVars map[string]string) error {
+	entries := make([]Entry, 0, len(envVars))
+	for _, v := range Sort(envVars) {
+		key, value, _ := strings.Cut(v, "=")
+		entries = append(entries, Entry{Key: key, Value: value})
+	}
+	return WriteEntries(w, entries)
+}
+
+// WriteEntries writes the entries to w like Write, in the given order. Literal values are quoted so
+// that loading the file doesn't expand them.
+func WriteEntries(w io.Writer, entries []Entry) error {
+	for _, e := range entries {
+		quoted, err := quoteValue(e.Key, e.Value, e.Literal)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", e.Key, quoted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unquotedValue matches values that are written without quotes. Other values are quoted, even if
+// the parser would accept them unquoted, to keep the file usable for shells.
+var unquotedValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=~-]*$`)
+
+// quoteValue returns value unquoted if possible, otherwise in double or single quotes, or in a verbatim
+// ``` block as a last resort. With literal, values with references are put in backticks instead.
+func quoteValue(key, value string, literal bool) (string, error) {
+	candidates := []string{`"` + value + `"`, "'" + value + "'", verbatimQuote + value + verbatimQuote}
+	if literal && strings.Contains(value, "$") {
+		candidates = append([]string{"`" + value + "`"}, candidates...)
+	}
+	if unquotedValue.MatchString(value) {
+		candidates = append([]string{value}, candidates...)
+	}
+	return firstParsed(key, value, candidates, literal)
+}
+
+// Quote returns value quoted for an env file: in single quotes, or in double quotes if it contains
+// single quotes or references. If the parser would read it differently, the other kind of quotes or
+// a verbatim ``` block is used. With literal, the value is loaded back unchanged: values with
+// references are put in backticks, which aren't expanded.
+func Quote(key, value string, literal bool) (string, error) {
+	candidates := []string{"'" + value + "'", `"` + value + `"`}
+	if strings.ContainsAny(value, "'$") {
+		candidates = []string{`"` + value + `"`, "'" + value + "'"}
+	}
+	if literal && strings.Contains(value, "$") {
+		candidates = append([]string{"`" + value + "`"}, candidates...)
+	}
+	return firstParsed(key, value, append(candidates, verbatimQuote+value+verbatimQuote), literal)
+}
+
+// firstParsed returns the first candidate representation of value that is read back as value. With
+// literal, candidates are read back as Load reads them, expanding references outside of backticks.
+func firstParsed(key, value string, candidates []string, literal bool) (string, error) {
+	for _, candidate := range candidates {
+		if v, ok := readBack(key, candidate, literal); ok && v == value {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s: value can't be represented in an env file", key)
+}
+
+// readBack returns the value of key defined as key=quoted, with references expanded if expand is set.
+func readBack(key, quoted string, expand bool) (string, bool) {
+	o := newOptions(nil)
+	o.noIncludes = true
+	parsed, err := parse(strings.NewReader(key+"="+quoted+"\n"), "", o)
+	if err != nil || len(parsed.vars) != 1 {
+		return "", false
+	}
+	value, ok := parsed.vars[key]
+	if !ok || !expand || isLiteralQuote(parsed.quotes[key]) {
+		return value, ok
+	}
+	envVars := map[string]string{key: value}
+	if err := expandWith(envVars, InterpolationDollarBrace, nil); err != nil {
+		return "", false
+	}
+	return envVars[key], true
+}
+
+// SetValue returns the env file content with the variable key set to value. The line defining key is
+// replaced, keeping an export prefix; other lines are left unchanged. If key is not defined, a line is
+// appended. The value is quoted where necessary and taken literally, references in it are not expanded.
+func SetValue(content []byte, key, value string) ([]byte, error) {
+	if !variableName.MatchString(key) {
+		return nil, fmt.Errorf("invalid variable name %q", key)
+	}
+	quoted, err := quoteValue(key, value, true)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(nil)
+	o.noIncludes = true
+	parsed, err := parse(bytes.NewReader(content), "", o)
+	if err != nil {
+		return nil, err
+	}
+
+	text := string(content)
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	lines := strings.SplitAfter(text, "\n")
+	// SplitAfter leaves an empty string after the final newline
+	lines = lines[:len(lines)-1]
+
+	start, defined := parsed.lines[key]
+	if !defined {
+		lines = append(lines, key+"="+quoted+"\n")
+		return []byte(strings.Join(lines, "")), nil
+	}
+
+	line := key + "=" + quoted + "\n"
+	if prefix := exportPrefix.FindString(strings.TrimSpace(lines[start-1])); prefix != "" {
+		line = prefix + line
+	}
+	lines = slices.Replace(lines, start-1, parsed.ends[key], line)
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// Reformat returns the env file content in a canonical form: line endings are LF, variables are sorted
+// by key with the comment block directly above them, trailing whitespace is removed and values are
+// written without quotes if possible and in double quotes otherwise. Values whose meaning could change,
+// such as values with references, escapes or several lines, keep their quoting. Comments before the
+// first blank line of the file stay on top. With groupByPrefix, a blank line separates the variables
+// whose keys differ before the first underscore.
+// Files with issues such as syntax errors or duplicate keys, and files with directives, are an error.
+func Reformat(content []byte, groupByPrefix bool) ([]byte, error) {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	o := newOptions(nil)
+	o.noIncludes = true
+	o.noExpand = true
+	parsed, err := parse(strings.NewReader(text), "", o)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.issues) > 0 {
+		return nil, errors.New(parsed.issues[0].String())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	starts := make(map[int]string, len(parsed.lines))
+	for key, line := range parsed.lines {
+		starts[line] = key
+	}
+
+	// A block is a variable with its comments, header holds the comments at the top of the file
+	type block struct {
+		key   string
+		lines []string
+	}
+	var (
+		header   []string
+		blocks   []block
+		comments []string
+	)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		key, isDefinition := starts[i+1]
+		switch {
+		case isDefinition:
+			definition := lines[i:parsed.ends[key]]
+			if len(definition) == 1 {
+				definition = []string{formatDefinition(key, line)}
+			}
+			blocks = append(blocks, block{key: key, lines: append(comments, definition...)})
+			comments = nil
+			i = parsed.ends[key] - 1
+		case strings.HasPrefix(trimmed, "#"):
+			comments = append(comments, trimmed)
+		case trimmed != "":
+			return nil, lineError("", i+1, errors.New("directives can't be formatted"))
+		case len(blocks) == 0 && len(comments) > 0:
+			header = append(header, comments...)
+			header = append(header, "")
+			comments = nil
+		case len(comments) > 0 && comments[len(comments)-1] != "":
+			// Comments separated from the next variable by a blank line stay separated
+			comments = append(comments, "")
+		}
+	}
+	slices.SortStableFunc(blocks, func(a, b block) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	out := slices.Clone(header)
+	for i, b := range blocks {
+		if i > 0 && separated(blocks[i-1].key, b.key, b.lines[0], groupByPrefix) {
+			out = append(out, "")
+		}
+		out = append(out, b.lines...)
+	}
+	// Comments at the end of the file stay at the end
+	if comments = slices.DeleteFunc(comments, func(c string) bool { return c == "" }); len(comments) > 0 {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, comments...)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(out, "\n") + "\n"), nil
+}
+
+// separated reports whether Reformat puts a blank line between the variables prevKey and key, where
+// firstLine is the first line of the block of key: blocks with comments are separated, and with
+// groupByPrefix variables whose keys differ before the first underscore.
+func separated(prevKey, key, firstLine string, groupByPrefix bool) bool {
+	prevGroup, _, _ := strings.Cut(prevKey, "_")
+	group, _, _ := strings.Cut(key, "_")
+	return strings.HasPrefix(firstLine, "#") || (groupByPrefix && group != prevGroup)
+}
+
+// formatDefinition returns a single line definition of key with its value requoted. An export prefix is
+// kept. Values with inline comments, references or escapes are left as they are.
+func formatDefinition(key, line string) string {
+	line = strings.TrimSpace(line)
+	prefix := exportPrefix.FindString(line)
+	_, raw, _ := strings.Cut(line, "=")
+	raw = strings.TrimSpace(raw)
+
+	value, quote := raw, ""
+	if len(raw) >= 2 && strings.ContainsRune(`"'`, rune(raw[0])) && raw[len(raw)-1] == raw[0] {
+		quote, value = raw[:1], raw[1:len(raw)-1]
+		if strings.Contains(value, quote) {
+			return prefix + key + "=" + raw
+		}
+	}
+
+	formatted := raw
+	switch {
+	case unquotedValue.MatchString(value):
+		formatted = value
+	case !strings.ContainsAny(value, "\"'`\\$#"):
+		formatted = `"` + value + `"`
+	}
+	// Keep the original if the parser would read the new form differently
+	before, errBefore := Parse(strings.NewReader(key + "=" + raw + "\n"))
+	after, errAfter := Parse(strings.NewReader(key + "=" + formatted + "\n"))
+	if errBefore != nil || errAfter != nil || before[key] != after[key] {
+		formatted = raw
+	}
+	return prefix + key + "=" + formatted
+}
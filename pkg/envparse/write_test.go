@@ -0,0 +1,98 @@
+package envparse
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		value   string
+		want    string
+	}{
+		{name: "append", content: "A=a\n", key: "B", value: "b", want: "A=a\nB=b\n"},
+		{name: "append without final newline", content: "A=a", key: "B", value: "b", want: "A=a\nB=b\n"},
+		{name: "replace", content: "# c\nA=a\nB=b\n", key: "A", value: "x y", want: "# c\nA=\"x y\"\nB=b\n"},
+		{name: "keep export", content: "export A=a\n", key: "A", value: "x", want: "export A=x\n"},
+		{name: "replace multiline", content: "A=\"a\nb\"\nB=b\n", key: "A", value: "x", want: "A=x\nB=b\n"},
+		{name: "reference", content: "", key: "A", value: "$HOME/x", want: "A=`$HOME/x`\n"},
+		{name: "reference with backticks", content: "", key: "A", value: "`${B}`", want: "A=``${B}``\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetValue([]byte(tt.content), tt.key, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetValueInvalidKey(t *testing.T) {
+	if _, err := SetValue(nil, "1A", "a"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestQuoteLiteralLoadsUnchanged(t *testing.T) {
+	for _, value := range []string{"a b", "$HOME/x", "${A:-b}", "it's $5", "a\n$B"} {
+		quoted, err := Quote("V", value, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := writeFiles(t, "V="+quoted+"\n")[0]
+		envVars, err := Load([]string{path}, WithVars(map[string]string{"HOME": "home", "A": "a", "B": "b"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if envVars["V"] != value {
+			content, _ := os.ReadFile(path)
+			t.Errorf("%q is loaded from %q as %q", value, content, envVars["V"])
+		}
+	}
+}
+
+func TestReformat(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		groupByPrefix bool
+		want          string
+	}{
+		{name: "sorted", content: "B=b\nA=a\n", want: "A=a\nB=b\n"},
+		{name: "line endings and whitespace", content: "B=b  \r\nA=a\r\n", want: "A=a\nB=b\n"},
+		{name: "requoted", content: "A='a'\nB='b c'\nD='d $E'\n", want: "A=a\nB=\"b c\"\nD='d $E'\n"},
+		{name: "export prefix", content: "export B='b'\nA=a\n", want: "A=a\nexport B=b\n"},
+		{name: "comments move with their variable", content: "# b\nB=b\n# a\nA=a\n", want: "# a\nA=a\n\n# b\nB=b\n"},
+		{name: "header", content: "# header\n\nB=b\nA=a\n", want: "# header\n\nA=a\nB=b\n"},
+		{name: "trailing comments", content: "B=b\nA=a\n\n# end\n", want: "A=a\nB=b\n\n# end\n"},
+		{name: "multiline", content: "B=b\nA=\"a\n  b\"\n", want: "A=\"a\n  b\"\nB=b\n"},
+		{name: "group by prefix", content: "DB_HOST=h\nAPP_NAME=n\nDB_PORT=1\n", groupByPrefix: true, want: "APP_NAME=n\n\nDB_HOST=h\nDB_PORT=1\n"},
+		{name: "empty", content: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Reformat([]byte(tt.content), tt.groupByPrefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReformatErrors(t *testing.T) {
+	for _, content := range []string{"A=a\nA=b\n", "A=\"a\n", "unset A\n", "include other.env\n"} {
+		if _, err := Reformat([]byte(content), false); err == nil {
+			t.Errorf("Reformat(%q) succeeded, want an error", content)
+		}
+	}
+}